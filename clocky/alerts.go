@@ -0,0 +1,244 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"appengine"
+	"appengine/memcache"
+)
+
+// Alert is a NWS watch, warning or advisory.  Severity, Urgency and
+// Certainty are as in the Common Alerting Protocol, such as "Severe",
+// "Expected" and "Likely".
+type Alert struct {
+	Event                        string // such as "Red Flag Warning"
+	Title, Summary, Link         string
+	Severity, Urgency, Certainty string
+	// Onset is when the hazard begins, and Expires is when the alert
+	// no longer applies.
+	Onset, Expires time.Time
+}
+
+// severities ranks CAP severities, most severe first.
+var severities = map[string]int{
+	"Extreme":  0,
+	"Severe":   1,
+	"Moderate": 2,
+	"Minor":    3,
+	"Unknown":  4,
+}
+
+func (a Alert) rank() int {
+	if r, ok := severities[a.Severity]; ok {
+		return r
+	}
+	return severities["Unknown"]
+}
+
+// Expired reports whether an alert no longer applies at a time.
+func (a Alert) Expired(now time.Time) bool {
+	return !a.Expires.IsZero() && !now.Before(a.Expires)
+}
+
+// ParseAlerts parses a NWS ATOM feed of CAP alerts, such as
+// http://alerts.weather.gov/cap/wwaatmget.php?x=CAZ006&y=0.  When there
+// are none, the feed has an entry saying so, which is skipped.  A time
+// that can't be parsed is passed to logf and left zero, so that the
+// alert is still shown, as if in effect from now until it is withdrawn.
+func ParseAlerts(r io.Reader, logf func(format string, args ...interface{})) ([]Alert, error) {
+	feed := struct {
+		Entry []struct {
+			Title   string `xml:"title"`
+			Summary string `xml:"summary"`
+			Link    struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Event     string `xml:"event"`
+			Effective string `xml:"effective"`
+			Onset     string `xml:"onset"`
+			Expires   string `xml:"expires"`
+			MsgType   string `xml:"msgType"`
+			Urgency   string `xml:"urgency"`
+			Severity  string `xml:"severity"`
+			Certainty string `xml:"certainty"`
+		} `xml:"entry"`
+	}{}
+	p := xml.NewDecoder(r)
+	// The feed's links have unescaped ampersands.
+	p.Strict = false
+	if err := p.Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	for _, e := range feed.Entry {
+		if e.Event == "" || e.MsgType == "Cancel" {
+			continue
+		}
+		a := Alert{
+			Event:     strings.TrimSpace(e.Event),
+			Title:     strings.TrimSpace(e.Title),
+			Summary:   strings.TrimSpace(e.Summary),
+			Link:      e.Link.Href,
+			Severity:  strings.TrimSpace(e.Severity),
+			Urgency:   strings.TrimSpace(e.Urgency),
+			Certainty: strings.TrimSpace(e.Certainty),
+		}
+		// CAP 1.1 feeds have no onset, so the alert's effective
+		// time stands in for it.
+		onset := e.Onset
+		if strings.TrimSpace(onset) == "" {
+			onset = e.Effective
+		}
+		var err error
+		if a.Onset, err = parseAlertTime(onset); err != nil {
+			logf("%s onset: %s", a.Event, err)
+		}
+		if a.Expires, err = parseAlertTime(e.Expires); err != nil {
+			logf("%s expiry: %s", a.Event, err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+func parseAlertTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("alerts: %s", err)
+	}
+	return t, nil
+}
+
+// activeAlerts returns the alerts that haven't expired, most severe
+// first, and soonest first among those as severe.
+func activeAlerts(alerts []Alert, now time.Time) []Alert {
+	var active []Alert
+	for _, a := range alerts {
+		if !a.Expired(now) {
+			active = append(active, a)
+		}
+	}
+	sort.Sort(bySeverity(active))
+	return active
+}
+
+type bySeverity []Alert
+
+func (s bySeverity) Len() int      { return len(s) }
+func (s bySeverity) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySeverity) Less(i, j int) bool {
+	if s[i].rank() != s[j].rank() {
+		return s[i].rank() < s[j].rank()
+	}
+	return s[i].Onset.Before(s[j].Onset)
+}
+
+// Alerts shows a banner for each alert in effect, across the top of the
+// page, moving the rest of it down.  Extreme and severe alerts are shown
+// in reverse.  Each banner is removed by the browser when its alert
+// expires, by the server's time, in case the page isn't reloaded first.
+func Alerts(w io.Writer, c appengine.Context, d *Display) {
+	item, err := memcache.Get(c, "alerts")
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	alerts, err := ParseAlerts(strings.NewReader(string(item.Value)), c.Warningf)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	location, err := time.LoadLocation(d.Zone)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	alertBanner(w, d, activeAlerts(alerts, time.Now()), location)
+}
+
+func alertBanner(w io.Writer, d *Display, alerts []Alert, location *time.Location) {
+	if len(alerts) == 0 {
+		return
+	}
+	type banner struct {
+		Event, When, Class string
+		Expires            int64
+	}
+	var banners []banner
+	now := time.Now()
+	for _, a := range alerts {
+		b := banner{Event: a.Event, Class: "alert"}
+		if a.rank() <= severities["Severe"] {
+			b.Class = "alert severe"
+		}
+		if a.Onset.After(now) {
+			b.When = "from " + alertTime(a.Onset.In(location), now.In(location), d)
+		}
+		if !a.Expires.IsZero() {
+			if b.When != "" {
+				b.When += " "
+			}
+			b.When += "until " + alertTime(a.Expires.In(location), now.In(location), d)
+			b.Expires = a.Expires.UnixNano() / int64(time.Millisecond)
+		}
+		banners = append(banners, b)
+	}
+	alertTmpl.Execute(w, banners)
+}
+
+// alertTime formats the time an alert begins or ends, with the day if it
+// isn't today.
+func alertTime(t, now time.Time, d *Display) string {
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format(d.TimeFormat())
+	}
+	return t.Format("Monday " + d.TimeFormat())
+}
+
+var alertTmpl = template.Must(template.New("alert").Parse(`
+<div id=alerts>
+{{range .}} <div class="{{.Class}}" data-expires="{{.Expires}}">{{.Event}} <span class=smaller>{{.When}}</span></div>
+{{end}}</div>
+<script>
+ (function() {
+  function expire() {
+   var banners = document.querySelectorAll("[data-expires]");
+   for (var i = 0; i < banners.length; i++) {
+    var expires = parseInt(banners[i].getAttribute("data-expires"), 10);
+    if (expires > 0 && serverTime() >= expires) {
+     banners[i].style.display = "none";
+    }
+   }
+   setTimeout(expire, 60000);
+  }
+  // The server left out alerts already expired, and the clock
+  // hasn't been corrected yet.
+  setTimeout(expire, 60000);
+ })();
+</script>
+`))
@@ -0,0 +1,172 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseAlertsFile(t *testing.T, name string) []Alert {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	alerts, err := ParseAlerts(f, t.Errorf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alerts
+}
+
+func TestParseAlerts(t *testing.T) {
+	alerts := parseAlertsFile(t, "testdata/alerts.xml")
+	if len(alerts) != 3 {
+		t.Fatalf("got %d alerts, want 3", len(alerts))
+	}
+	pdt := time.FixedZone("PDT", -7*3600)
+	a := alerts[0]
+	if a.Event != "Red Flag Warning" || a.Severity != "Severe" || a.Urgency != "Expected" ||
+		a.Certainty != "Likely" {
+		t.Errorf("alerts[0] = %+v", a)
+	}
+	if want := time.Date(2012, 9, 25, 11, 0, 0, 0, pdt); !a.Onset.Equal(want) {
+		t.Errorf("Red Flag Warning onset %v, want %v", a.Onset, want)
+	}
+	if want := time.Date(2012, 9, 26, 18, 0, 0, 0, pdt); !a.Expires.Equal(want) {
+		t.Errorf("Red Flag Warning expires %v, want %v", a.Expires, want)
+	}
+	if !strings.HasPrefix(a.Summary, "...RED FLAG WARNING") || !strings.Contains(a.Link, "RedFlagWarning") {
+		t.Errorf("Red Flag Warning summary %q link %q", a.Summary, a.Link)
+	}
+	// Without an onset, it is when the alert took effect.
+	if want := time.Date(2012, 9, 25, 1, 40, 0, 0, pdt); !alerts[1].Onset.Equal(want) {
+		t.Errorf("Heat Advisory onset %v, want %v", alerts[1].Onset, want)
+	}
+
+	if alerts := parseAlertsFile(t, "testdata/alerts-none.xml"); len(alerts) != 0 {
+		t.Errorf("got %d alerts when there are none", len(alerts))
+	}
+}
+
+// An alert with a time that can't be parsed is still shown, and so are
+// the others.
+func TestParseAlertsBadTime(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/alerts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := strings.Replace(string(data),
+		"<cap:expires>2012-09-26T18:00:00-07:00</cap:expires>",
+		"<cap:expires>Wednesday at 6 PM</cap:expires>", 1)
+	if feed == string(data) {
+		t.Fatal("Red Flag Warning's expiry not found")
+	}
+	var logged []string
+	logf := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	alerts, err := ParseAlerts(strings.NewReader(feed), logf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 3 {
+		t.Fatalf("got %d alerts, want 3", len(alerts))
+	}
+	if a := alerts[0]; a.Event != "Red Flag Warning" || !a.Expires.IsZero() || a.Onset.IsZero() {
+		t.Errorf("alerts[0] = %+v", a)
+	}
+	if alerts[1].Expires.IsZero() {
+		t.Errorf("alerts[1] lost its expiry")
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "Red Flag Warning") {
+		t.Errorf("logged %q", logged)
+	}
+	active := activeAlerts(alerts, time.Date(2012, 9, 28, 12, 0, 0, 0, time.UTC))
+	if len(active) == 0 || active[0].Event != "Red Flag Warning" {
+		t.Errorf("active alerts %+v, want the Red Flag Warning still", active)
+	}
+}
+
+func TestActiveAlerts(t *testing.T) {
+	alerts := parseAlertsFile(t, "testdata/alerts.xml")
+	pdt := time.FixedZone("PDT", -7*3600)
+	cases := []struct {
+		now  time.Time
+		want []string
+	}{
+		{time.Date(2012, 9, 25, 1, 0, 0, 0, pdt),
+			[]string{"Red Flag Warning", "Wind Advisory", "Heat Advisory"}},
+		{time.Date(2012, 9, 25, 2, 0, 0, 0, pdt),
+			[]string{"Red Flag Warning", "Heat Advisory"}},
+		{time.Date(2012, 9, 26, 19, 0, 0, 0, pdt),
+			[]string{"Heat Advisory"}},
+		{time.Date(2012, 9, 26, 20, 0, 0, 0, pdt), nil},
+	}
+	for _, tt := range cases {
+		var got []string
+		for _, a := range activeAlerts(alerts, tt.now) {
+			got = append(got, a.Event)
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("active at %v: %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestAlertBanner(t *testing.T) {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	alerts := []Alert{
+		{Event: "Tsunami Warning", Severity: "Extreme", Expires: now.Add(2 * time.Hour)},
+		{Event: "Heat Advisory", Severity: "Minor", Onset: now.Add(48 * time.Hour),
+			Expires: now.Add(60 * time.Hour)},
+	}
+	var b bytes.Buffer
+	alertBanner(&b, &Display{}, alerts, location)
+	s := b.String()
+	for _, want := range []string{
+		`<div class="alert severe" data-expires="`,
+		`>Tsunami Warning <span class=smaller>until `,
+		`<div class="alert" data-expires="`,
+		`>Heat Advisory <span class=smaller>from ` + now.Add(48*time.Hour).In(location).Format("Monday"),
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%q not in %s", want, s)
+		}
+	}
+	// The banner is laid out above the clock, not on top of it, and
+	// expires by the corrected clock.
+	for _, bad := range []string{"position", "new Date()"} {
+		if strings.Contains(s, bad) {
+			t.Errorf("%q in %s", bad, s)
+		}
+	}
+
+	b.Reset()
+	alertBanner(&b, &Display{}, nil, location)
+	if b.Len() != 0 {
+		t.Errorf("banner without alerts: %s", b.String())
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"appengine"
+	"appengine/memcache"
+)
+
+// Size of the hourly chart, and the margins around the plot for labels,
+// in pixels.
+const (
+	chartWidth, chartHeight = 400, 96
+	chartLeft, chartRight   = 28, 8
+	chartTop, chartBottom   = 12, 18
+)
+
+// HourlyChart shows the temperature and chance of rain for the next few
+// hours, from the api.weather.gov hourly forecast.
+func HourlyChart(w io.Writer, c appengine.Context, d *Display) {
+	if d.ChartHours == 0 {
+		return
+	}
+	location, err := time.LoadLocation(d.Zone)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	item, err := memcache.Get(c, "weathergov")
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	_, hours, err := ParseWeatherGov(item.Value)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	hourlyChart(w, d, upcoming(hours, time.Now(), d.ChartHours), location)
+}
+
+// upcoming returns up to n hours of an hourly forecast, starting with the
+// current hour.
+func upcoming(hours []Period, now time.Time, n int) []Period {
+	for len(hours) > 0 && !hours[0].Start.Add(time.Hour).After(now) {
+		hours = hours[1:]
+	}
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// hourlyChart draws the chance of rain as gray bars, and the temperature
+// as a black line over them, which stay distinct on a grayscale display.
+// The highest and lowest temperatures are labeled, and so is the highest
+// chance of rain if it is worth mentioning.  The hours are labeled every
+// six hours, in location.
+func hourlyChart(w io.Writer, d *Display, hours []Period, location *time.Location) {
+	if len(hours) < 2 {
+		return
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	wettest := -1
+	for i, h := range hours {
+		if h.Temp != nil {
+			t := h.Temp.In(d.Units.Temperature)
+			lo, hi = math.Min(lo, t), math.Max(hi, t)
+		}
+		if h.PoP != nil && (wettest < 0 || *h.PoP > *hours[wettest].PoP) {
+			wettest = i
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return
+	}
+	warmest, coolest := hi, lo
+	if hi-lo < 4 {
+		// Don't exaggerate small changes.
+		mid := (hi + lo) / 2
+		hi, lo = mid+2, mid-2
+	}
+
+	const plotWidth = chartWidth - chartLeft - chartRight
+	const plotHeight = chartHeight - chartTop - chartBottom
+	step := float64(plotWidth) / float64(len(hours))
+	x := func(i int) float64 { return chartLeft + step*float64(i) }
+	y := func(t float64) float64 { return chartTop + plotHeight*(hi-t)/(hi-lo) }
+
+	fmt.Fprintf(w, `<svg width=%d height=%d viewBox="0 0 %d %d" font-size=11>`,
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	for i, h := range hours {
+		if h.PoP == nil || *h.PoP == 0 {
+			continue
+		}
+		bh := plotHeight * *h.PoP / 100
+		fmt.Fprintf(w, `<rect x=%.1f y=%.1f width=%.1f height=%.1f fill="#bbb" />`,
+			x(i)+0.5, chartTop+plotHeight-bh, step-1, bh)
+	}
+	if wettest >= 0 && *hours[wettest].PoP >= 20 {
+		p := *hours[wettest].PoP
+		fmt.Fprintf(w, `<text x=%.1f y=%.1f text-anchor=middle fill="#666">%.0f%%</text>`,
+			x(wettest)+step/2, chartTop+plotHeight*(1-p/100)-2, p)
+	}
+	fmt.Fprintf(w, `<line x1=%d y1=%d x2=%d y2=%d stroke=black />`,
+		chartLeft, chartTop+plotHeight, chartWidth-chartRight, chartTop+plotHeight)
+
+	io.WriteString(w, `<polyline fill=none stroke=black stroke-width=2.5 points="`)
+	for i, h := range hours {
+		if h.Temp != nil {
+			fmt.Fprintf(w, "%.1f,%.1f ", x(i)+step/2, y(h.Temp.In(d.Units.Temperature)))
+		}
+	}
+	io.WriteString(w, `" />`)
+	labels := []float64{warmest, coolest}
+	if math.Floor(warmest+0.5) == math.Floor(coolest+0.5) {
+		labels = labels[:1]
+	}
+	for _, t := range labels {
+		fmt.Fprintf(w, `<text x=%d y=%.1f text-anchor=end>%.0f°</text>`,
+			chartLeft-4, y(t)+4, t)
+	}
+
+	layout := "3pm"
+	if d.Hour24 {
+		layout = "15h"
+	}
+	for i, h := range hours {
+		t := h.Start.In(location)
+		if t.Hour()%6 != 0 {
+			continue
+		}
+		fmt.Fprintf(w, `<line x1=%.1f y1=%d x2=%.1f y2=%d stroke=black />`,
+			x(i), chartTop+plotHeight, x(i), chartTop+plotHeight+4)
+		fmt.Fprintf(w, `<text x=%.1f y=%d text-anchor=middle>%s</text>`,
+			x(i), chartHeight-2, t.Format(layout))
+	}
+	io.WriteString(w, `</svg>`)
+}
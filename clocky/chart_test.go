@@ -0,0 +1,90 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"units"
+)
+
+func testHours(t *testing.T) []Period {
+	var data weatherGovData
+	var err error
+	if data.Forecast, err = ioutil.ReadFile("testdata/weathergov/forecast.json"); err != nil {
+		t.Fatal(err)
+	}
+	if data.Hourly, err = ioutil.ReadFile("testdata/weathergov/hourly.json"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hours, err := ParseWeatherGov(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hours
+}
+
+func TestUpcoming(t *testing.T) {
+	hours := testHours(t)
+	now := hours[3].Start.Add(20 * time.Minute)
+	got := upcoming(hours, now, 24)
+	if len(got) != 24 || !got[0].Start.Equal(hours[3].Start) {
+		t.Errorf("upcoming at %v starts at %v with %d hours, want %v with 24",
+			now, got[0].Start, len(got), hours[3].Start)
+	}
+	if got := upcoming(hours, hours[47].Start.Add(time.Hour), 24); len(got) != 0 {
+		t.Errorf("upcoming after the forecast has %d hours, want 0", len(got))
+	}
+}
+
+func TestHourlyChart(t *testing.T) {
+	hours := testHours(t)[:24]
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	hourlyChart(&b, &Display{}, hours, location)
+	s := b.String()
+	if n := strings.Count(s, "<rect "); n != 24 {
+		t.Errorf("%d bars, want 24", n)
+	}
+	if n := strings.Count(s, "<polyline "); n != 1 {
+		t.Errorf("%d lines, want 1", n)
+	}
+	for _, want := range []string{">13°<", ">9°<", ">70%<", ">6pm<", ">12am<", ">6am<", ">12pm<"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%q not in chart", want)
+		}
+	}
+
+	b.Reset()
+	hourlyChart(&b, &Display{Hour24: true, Units: units.Imperial}, hours, location)
+	s = b.String()
+	for _, want := range []string{">55°<", ">48°<", ">18h<", ">00h<"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%q not in chart", want)
+		}
+	}
+}
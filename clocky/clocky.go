@@ -15,6 +15,7 @@
 package clocky
 
 import (
+	"html/template"
 	"io"
 	"net/http"
 
@@ -29,34 +30,101 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	d, ok := Displays[r.FormValue("display")]
+	if !ok {
+		http.Error(w, "Unknown display", http.StatusNotFound)
+		return
+	}
+
 	c := appengine.NewContext(r)
 	ch := make(chan error)
 	go func() { ch <- freshenAll(c) }()
 
-	// TODO: Refresh less often; use JS to tick clock.
-	// TODO: Have browser refresh; safer since error pages will get retried.
-	w.Header().Set("Refresh", "2")
+	// The script below reloads the page when there is new data.  In
+	// case it stops, the browser also reloads the page every hour.
+	w.Header().Set("Refresh", "3600")
 
 	io.WriteString(w, header)
+	// The alert banners push everything at the top of the page down.
+	Alerts(w, c, d)
+	io.WriteString(w, `<div style="position: relative; margin: 0">`)
 
-	io.WriteString(w, `<div class=box style="width: 350px; height: 128px; top: 24px; left:28px; text-align: center; background-color: #eee">`)
-	Time(w, c)
+	io.WriteString(w, `<div class=box style="width: 350px; height: 224px; top: 24px; left:28px; text-align: center; background-color: #eee">`)
+	Time(w, c, d)
 	io.WriteString(w, `</div>`)
 
-	io.WriteString(w, `<div class=box style="width: 400px; top: 170px; left: 24px">`)
-	Conditions(w, c)
-	Forecast(w, c)
+	io.WriteString(w, `<div class=box style="top: 40px; left: 388px">`)
+	SunArc(w, c)
 	io.WriteString(w, `</div>`)
 
-	io.WriteString(w, `<div class=box style="width: 320px; top: 16px; left: 460px; font-size: 20px">`)
+	io.WriteString(w, `<div class=box style="width: 400px; top: 266px; left: 24px">`)
+	Conditions(w, c, d)
+	HourlyChart(w, c, d)
+	Forecast(w, c, d)
+	io.WriteString(w, `</div>`)
+
+	io.WriteString(w, `<div class=box id=nextbus style="width: 320px; top: 16px; left: 460px; font-size: 20px">`)
 	NextBus(w, c)
 	io.WriteString(w, `</div>`)
+	io.WriteString(w, `</div>`)
+
+	io.WriteString(w, `<div class=box style="width: 320px; bottom: 16px; left: 460px; font-size: 20px">`)
+	Secondary(w, c, d)
+	io.WriteString(w, `</div>`)
 
 	if err := <-ch; err != nil {
 		c.Errorf("%s", err)
 	}
+
+	// The page is reloaded only when there is new data.
+	stamp, err := dataStamp(c, d)
+	if err != nil {
+		c.Errorf("%s", err)
+	}
+	pollTmpl.Execute(w, map[string]string{
+		"Stamp":   stamp,
+		"Display": r.FormValue("display"),
+	})
 }
 
+// pollTmpl asks the server every few seconds whether the data the display
+// shows has changed since the page was rendered, and reloads the page if
+// so.  The
+// request also keeps the data fresh.  The bus predictions change too
+// often for that, so they are replaced in place.
+var pollTmpl = template.Must(template.New("poll").Parse(`
+<script>
+ (function() {
+  var stamp = {{.Stamp}}, display = {{.Display}};
+  function get(url, done) {
+   var req = new XMLHttpRequest();
+   req.onreadystatechange = function() {
+    if (req.readyState == 4) {
+     done(req);
+    }
+   };
+   req.open("GET", url + (url.indexOf("?") < 0 ? "?" : "&") + new Date().getTime(), true);
+   req.send(null);
+  }
+  function poll() {
+   get("/stamp?display=" + encodeURIComponent(display), function(req) {
+    if (req.status == 200 && req.responseText != stamp) {
+     location.reload();
+     return;
+    }
+    get("/nextbus", function(req) {
+     if (req.status == 200) {
+      document.getElementById("nextbus").innerHTML = req.responseText;
+     }
+     setTimeout(poll, 10000);
+    });
+   });
+  }
+  setTimeout(poll, 10000);
+ })();
+</script>
+`))
+
 func init() {
 	http.HandleFunc("/", handler)
 }
@@ -74,6 +142,20 @@ const header = `<!DOCTYPE html>
         .bus { margin: 8px 0 8px 0; }
         .route { font-size: 24px; font-weight: bold; }
         .munimessage { font-style: italic; }
+        .stale { color: #888; font-style: italic; }
+        .station { font-size: 40%; font-weight: normal; color: #666; margin-left: 2px; }
+        .icon { width: 32px; height: 32px; vertical-align: middle; margin-right: 6px; }
+        .alert { font-weight: bold; padding: 4px 8px; border: 4px solid black; background-color: white; }
+        .severe { color: white; background-color: black; }
+        .day, .night { display: inline-block; width: 10px; height: 10px; border: 2px solid black; border-radius: 7px; }
+        .night { background-color: black; }
     </style>
+    <script>
+        // skew is how far the device's clock is behind the server's,
+        // in milliseconds, as the clock has worked out.  Everything
+        // timed in the browser should use serverTime.
+        var skew = 0;
+        function serverTime() { return new Date().getTime() + skew; }
+    </script>
 </head>
 `
@@ -0,0 +1,175 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bufio"
+	"html"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"appengine"
+	"appengine/memcache"
+)
+
+// Discussion is a NWS Area Forecast Discussion, in which the forecasters
+// explain their reasoning, divided into its sections.
+type Discussion struct {
+	// Issued is when it was issued, as written in the product, such
+	// as "312 AM PDT TUE SEP 25 2012".
+	Issued   string
+	Sections []Section
+}
+
+// Section is a section of a discussion, such as "SYNOPSIS" or "MARINE".
+// Its text is unwrapped into paragraphs.
+type Section struct {
+	Name       string
+	Paragraphs []string
+}
+
+// Section returns the section with a name, such as "SYNOPSIS", ignoring
+// case, or nil if there is none.
+func (d *Discussion) Section(name string) *Section {
+	for i, s := range d.Sections {
+		if strings.EqualFold(s.Name, name) {
+			return &d.Sections[i]
+		}
+	}
+	return nil
+}
+
+var (
+	// A section begins with a line like ".SYNOPSIS...Text".  Headings
+	// that are indented are part of a section's text.
+	sectionRegexp = regexp.MustCompile(`^\.([A-Za-z][A-Za-z0-9 /]*?)\.\.\.(.*)$`)
+	issuedRegexp  = regexp.MustCompile(`^[0-9]{3,4} [AP]M [A-Z]{3,4} [A-Za-z]{3} [A-Za-z]{3} [0-9]{1,2} [0-9]{4}$`)
+	preRegexp     = regexp.MustCompile(`(?s)<pre[^>]*>(.*?)</pre>`)
+)
+
+// ParseDiscussion parses an Area Forecast Discussion, either as plain
+// text or in the <pre> of a NWS product page.  Sections end with "&&",
+// and the product with "$$".
+func ParseDiscussion(r io.Reader) (*Discussion, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(b)
+	if m := preRegexp.FindStringSubmatch(text); m != nil {
+		text = html.UnescapeString(m[1])
+	}
+
+	d := &Discussion{}
+	var section *Section
+	var para []string
+	endParagraph := func() {
+		if section != nil && len(para) > 0 {
+			section.Paragraphs = append(section.Paragraphs, strings.Join(para, " "))
+		}
+		para = nil
+	}
+	endSection := func() {
+		endParagraph()
+		if section != nil {
+			d.Sections = append(d.Sections, *section)
+		}
+		section = nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		switch {
+		case line == "$$":
+			endSection()
+			return d, nil
+		case line == "&&":
+			endSection()
+		case sectionRegexp.MatchString(line):
+			endSection()
+			m := sectionRegexp.FindStringSubmatch(line)
+			section = &Section{Name: m[1]}
+			if rest := strings.TrimSpace(m[2]); rest != "" {
+				para = append(para, rest)
+			}
+		case d.Issued == "" && section == nil && issuedRegexp.MatchString(line):
+			d.Issued = line
+		case strings.TrimSpace(line) == "":
+			endParagraph()
+		default:
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	endSection()
+	return d, nil
+}
+
+// truncate shortens text to at most n characters, at a word boundary,
+// with an ellipsis if anything was cut.  NWS also separates phrases with
+// "...", without spaces.
+func truncate(text string, n int) string {
+	r := []rune(text)
+	if len(r) <= n {
+		return text
+	}
+	s := string(r[:n-1])
+	i := strings.LastIndex(s, " ")
+	if j := strings.LastIndex(s, "..."); j > i {
+		i = j
+	}
+	if i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, " .,;") + "\u2026"
+}
+
+// synopsisLength is how much of the synopsis fits in a panel.
+const synopsisLength = 280
+
+// ForecastDiscussion shows the synopsis from the Area Forecast
+// Discussion, which says more about the marine layer than any forecast.
+func ForecastDiscussion(w io.Writer, c appengine.Context, d *Display) {
+	item, err := memcache.Get(c, "discussion")
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	disc, err := ParseDiscussion(strings.NewReader(string(item.Value)))
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	synopsis := disc.Section("SYNOPSIS")
+	if synopsis == nil || len(synopsis.Paragraphs) == 0 {
+		c.Warningf("discussion: no synopsis")
+		return
+	}
+	discussionTmpl.Execute(w, map[string]string{
+		"Issued":   disc.Issued,
+		"Synopsis": truncate(strings.Join(synopsis.Paragraphs, " "), synopsisLength),
+	})
+}
+
+var discussionTmpl = template.Must(template.New("discussion").Parse(`
+ <div class=header>Forecast discussion</div>
+ <div class=smaller>{{.Synopsis}}</div>
+ <div class=smaller style="color: #666">{{.Issued}}</div>
+`))
@@ -0,0 +1,97 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseDiscussion(t *testing.T) {
+	f, err := os.Open("testdata/AFDMTR.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := ParseDiscussion(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "312 AM PDT TUE SEP 25 2012"; d.Issued != want {
+		t.Errorf("Issued = %q, want %q", d.Issued, want)
+	}
+	var names []string
+	for _, s := range d.Sections {
+		names = append(names, s.Name)
+	}
+	if got, want := strings.Join(names, ", "),
+		"SYNOPSIS, DISCUSSION, AVIATION, MARINE, MTR WATCHES/WARNINGS/ADVISORIES"; got != want {
+		t.Errorf("sections are %s, want %s", got, want)
+	}
+
+	s := d.Section("synopsis")
+	if s == nil || len(s.Paragraphs) != 1 {
+		t.Fatalf("synopsis = %+v, want one paragraph", s)
+	}
+	if want := "A WEAK OFFSHORE FLOW PATTERN WILL BRING WARMER AND DRIER CONDITIONS TO THE REGION"; !strings.HasPrefix(s.Paragraphs[0], want) {
+		t.Errorf("synopsis = %q, want prefix %q", s.Paragraphs[0], want)
+	}
+	if !strings.HasSuffix(s.Paragraphs[0], "AS ONSHORE FLOW RETURNS AND THE MARINE LAYER DEEPENS.") {
+		t.Errorf("synopsis = %q, want all of it", s.Paragraphs[0])
+	}
+	if s := d.Section("DISCUSSION"); s == nil || len(s.Paragraphs) != 2 ||
+		!strings.HasPrefix(s.Paragraphs[0], "AS OF 3:12 AM PDT TUESDAY...THE FORT ORD PROFILER") {
+		t.Errorf("discussion = %+v", s)
+	}
+	// Indented headings are part of the section.
+	if s := d.Section("MTR WATCHES/WARNINGS/ADVISORIES"); s == nil ||
+		!strings.Contains(s.Paragraphs[0], ".TDAY...RED FLAG WARNING") {
+		t.Errorf("watches = %+v", s)
+	}
+	if d.Section("FIRE WEATHER") != nil {
+		t.Errorf("found a section that isn't there")
+	}
+}
+
+func TestParseDiscussionText(t *testing.T) {
+	text := "AREA FORECAST DISCUSSION\n\n.SYNOPSIS...Fog tonight.\n\n&&\n\n.MARINE...Calm.\n"
+	d, err := ParseDiscussion(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Sections) != 2 || d.Sections[0].Paragraphs[0] != "Fog tonight." ||
+		d.Sections[1].Paragraphs[0] != "Calm." {
+		t.Errorf("sections = %+v", d.Sections)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"Fog tonight.", 20, "Fog tonight."},
+		{"Fog tonight, clearing tomorrow.", 20, "Fog tonight\u2026"},
+		{"Fog tonight, clearing tomorrow.", 31, "Fog tonight, clearing tomorrow."},
+		{"Fog...clearing", 10, "Fog\u2026"},
+	}
+	for _, tt := range cases {
+		if got := truncate(tt.text, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"time"
+
+	"units"
+)
+
+// Display is the configuration of one device showing Clocky.
+type Display struct {
+	// Zone is the IANA name of the time zone, such as
+	// "America/Los_Angeles".  The zoneinfo database must be
+	// available to the server.
+	Zone string
+
+	// Hour24 shows the time on a 24-hour clock, rather than with
+	// am and pm.
+	Hour24 bool
+
+	// DateFormat is the layout of the date, as for time.Format.
+	// Different orders suit different locales, such as "Monday,
+	// January 2" or "Monday 2 January".
+	DateFormat string
+
+	// MaxDrift is how far the device's own clock may be from the
+	// server's before a warning is shown.  The clock shown is always
+	// corrected.  Zero means never warn.
+	MaxDrift time.Duration
+
+	// Stations are the NDBC stations to show current conditions from,
+	// in order of preference.  If none of them reports something,
+	// the nearest station that does is used.
+	Stations []string
+
+	// Fields are the current conditions to show.  The temperature
+	// ("temp") and wind, or wind chill, ("wind") are shown large;
+	// any of "gust", "pressure", "visibility" and "dewpoint" are
+	// shown below, in the order given.  "outlook" adds a forecast
+	// from the barometer on a line of its own, and "trends" adds
+	// graphs of the last day and today's high and low.
+	Fields []string
+
+	// Observations older than StaleAfter are shown as stale, and
+	// those older than HideAfter are not used at all.  Zero means
+	// never.
+	StaleAfter, HideAfter time.Duration
+
+	// ForecastSource is the source of the forecast in Sources:
+	// "forecast", NWS's older MapClick DWML, which is the default, or
+	// "weathergov", from api.weather.gov.
+	ForecastSource string
+
+	// ForecastText shows the worded forecast for each period, not just
+	// its high or low, chance of rain and summary.
+	ForecastText bool
+
+	// ChartHours is how many hours ahead to chart the temperature and
+	// chance of rain, from the api.weather.gov hourly forecast.  Zero
+	// means no chart.
+	ChartHours int
+
+	// Units are the units quantities are shown in.  The zero value
+	// is metric.
+	Units units.System
+
+	// WorldClocks are other places whose time is shown.
+	WorldClocks []WorldClock
+
+	// Panels share the box in the corner, taking turns every
+	// RotateEvery, which defaults to 30 seconds.  They are
+	// "worldclocks", the default, and "discussion", the synopsis
+	// of the NWS Area Forecast Discussion.
+	Panels      []string
+	RotateEvery time.Duration
+}
+
+// WorldClock is a place whose time is shown alongside the local time.
+// Its location is used to tell whether it is day or night there.
+type WorldClock struct {
+	Label    string
+	Zone     string
+	Lat, Lng float64
+}
+
+// Displays are chosen by the "display" query parameter.  The empty name
+// is used when there is none.  They all show the sky, weather and buses
+// at Lat and Lng; only how they are shown differs.
+var Displays = map[string]*Display{
+	"": &Display{
+		Zone:       "America/Los_Angeles",
+		DateFormat: "Monday, January 2",
+		MaxDrift:   30 * time.Second,
+		// FTPC1 is a C-MAN automated buoy near Crissy Field.
+		Stations: []string{"FTPC1"},
+		// Fog and gusts matter more than temperature here.
+		Fields:     []string{"temp", "wind", "gust", "visibility", "pressure", "dewpoint", "outlook", "trends"},
+		StaleAfter: 1 * time.Hour,
+		HideAfter:  3 * time.Hour,
+		// Will it rain on the walk home?
+		ChartHours: 24,
+		// Sailors on the team want knots.
+		Units: units.System{
+			Temperature: units.Celsius,
+			Speed:       units.Knots,
+			Pressure:    units.Hectopascals,
+			Distance:    units.Kilometres,
+		},
+		WorldClocks: []WorldClock{
+			{"London", "Europe/London", 51.51, -0.13},
+			{"Tokyo", "Asia/Tokyo", 35.69, 139.69},
+		},
+		// The forecasters' notes on the marine layer say the
+		// most about fog.
+		Panels: []string{"worldclocks", "discussion"},
+	},
+	// The lobby display is for visitors from the US.
+	"lobby": &Display{
+		Zone:           "America/Los_Angeles",
+		DateFormat:     "Monday, January 2",
+		MaxDrift:       30 * time.Second,
+		Stations:       []string{"FTPC1"},
+		Fields:         []string{"temp", "wind", "visibility"},
+		StaleAfter:     1 * time.Hour,
+		HideAfter:      3 * time.Hour,
+		Units:          units.Imperial,
+		ForecastSource: "weathergov",
+	},
+}
+
+// TimeFormat returns the layout for showing a time of day.
+func (d *Display) TimeFormat() string {
+	if d.Hour24 {
+		return "15:04"
+	}
+	return "3:04\u2009pm"
+}
+
+// clockFormats returns the layouts for the large and small parts of the
+// clock.
+func (d *Display) clockFormats() (big, small string) {
+	if d.Hour24 {
+		return "15:04", ":05"
+	}
+	return "3:04", ":05\u2009pm"
+}
+
+// forecastSource returns the key in Sources of the display's forecast.
+func (d *Display) forecastSource() string {
+	if d.ForecastSource == "" {
+		return "forecast"
+	}
+	return d.ForecastSource
+}
+
+// sources returns the keys in Sources of the data the display shows,
+// other than the bus predictions, which the page updates in place.
+func (d *Display) sources() []string {
+	keys := []string{"alerts", d.forecastSource()}
+	if len(d.Fields) > 0 {
+		keys = append(keys, "conditions")
+	}
+	if d.ChartHours > 0 && d.forecastSource() != "weathergov" {
+		keys = append(keys, "weathergov")
+	}
+	for _, p := range d.Panels {
+		if p == "discussion" {
+			keys = append(keys, "discussion")
+		}
+	}
+	return keys
+}
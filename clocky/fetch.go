@@ -15,10 +15,15 @@
 package clocky
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +37,19 @@ import (
 type Source struct {
 	URL                 string
 	Refresh, Expiration time.Duration
+
+	// Get, if set, fetches the data instead of fetching URL, for
+	// sources that take more than one request.
+	Get func(client *http.Client) ([]byte, error)
+
+	// Record, if set, is called with each copy of the data fetched,
+	// to keep what is wanted from it after it expires.
+	Record func(c appengine.Context, data []byte) error
+
+	// Shown, if set, returns what is shown from the data, without
+	// what changes each time it is fetched, such as when it was
+	// generated.  The page is reloaded when it changes.
+	Shown func(data []byte) (interface{}, error)
 }
 
 var Sources = map[string]Source{
@@ -49,11 +67,50 @@ var Sources = map[string]Source{
 		Refresh:    10 * time.Second,
 		Expiration: 5 * time.Minute,
 	},
+	// The forecast is fetched in metric units (unit=1), and converted
+	// for each display.
 	"forecast": Source{
 		URL: ("http://forecast.weather.gov/MapClick.php?" +
 			"lat=37.79570&lon=-122.42100&FcstType=dwml&unit=1"),
 		Refresh:    1 * time.Hour,
 		Expiration: 8 * time.Hour,
+		Shown: func(data []byte) (interface{}, error) {
+			return ParseDWML(bytes.NewReader(data))
+		},
+	},
+	// The same forecast from the newer api.weather.gov, with the
+	// hourly forecast too.
+	"weathergov": Source{
+		Get: func(client *http.Client) ([]byte, error) {
+			return getWeatherGov(client, weatherGovURL, Lat, Lng)
+		},
+		Refresh:    1 * time.Hour,
+		Expiration: 8 * time.Hour,
+		Shown: func(data []byte) (interface{}, error) {
+			periods, hours, err := ParseWeatherGov(data)
+			return [][]Period{periods, hours}, err
+		},
+	},
+	// NWS watches, warnings and advisories for San Francisco.
+	// http://alerts.weather.gov/
+	"alerts": Source{
+		URL:        "http://alerts.weather.gov/cap/wwaatmget.php?x=CAZ006&y=0",
+		Refresh:    2 * time.Minute,
+		Expiration: 1 * time.Hour,
+		Shown: func(data []byte) (interface{}, error) {
+			return ParseAlerts(bytes.NewReader(data), func(string, ...interface{}) {})
+		},
+	},
+	// The Area Forecast Discussion from the San Francisco Bay Area
+	// office.
+	"discussion": Source{
+		URL: ("http://forecast.weather.gov/product.php?" +
+			"site=MTR&issuedby=MTR&product=AFD&format=txt&version=1&glossary=0"),
+		Refresh:    30 * time.Minute,
+		Expiration: 12 * time.Hour,
+		Shown: func(data []byte) (interface{}, error) {
+			return ParseDiscussion(bytes.NewReader(data))
+		},
 	},
 	// NDBC latest observations for all points.  This file is much
 	// smaller than the file for any individual station, because
@@ -63,6 +120,8 @@ var Sources = map[string]Source{
 		URL:        "http://www.ndbc.noaa.gov/data/latest_obs/latest_obs.txt",
 		Refresh:    6 * time.Minute,
 		Expiration: 30 * time.Minute,
+		Record:     recordObservations,
+		Shown:      shownObservations,
 	},
 }
 
@@ -74,21 +133,30 @@ func fetch(c appengine.Context, key string) error {
 
 	c.Debugf("fetching %s data", key)
 	transport := urlfetch.Transport{Context: c, Deadline: 60 * time.Second}
-	req, err := http.NewRequest("GET", s.URL, strings.NewReader(""))
-	if err != nil {
-		return err
-	}
-	resp, err := transport.RoundTrip(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("fetch: bad status %d for %s", resp.StatusCode, s.URL)
-	}
-	contents, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	var contents []byte
+	if s.Get != nil {
+		var err error
+		contents, err = s.Get(&http.Client{Transport: &transport})
+		if err != nil {
+			return err
+		}
+	} else {
+		req, err := http.NewRequest("GET", s.URL, strings.NewReader(""))
+		if err != nil {
+			return err
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetch: bad status %d for %s", resp.StatusCode, s.URL)
+		}
+		contents, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
 	}
 
 	item := &memcache.Item{
@@ -116,6 +184,14 @@ func fetch(c appengine.Context, key string) error {
 	}
 
 	c.Infof("cached %d bytes of %s data", len(contents), key)
+
+	// The data is cached, so failing to record it shouldn't fail the
+	// fetch and have it retried.
+	if s.Record != nil {
+		if err := s.Record(c, contents); err != nil {
+			c.Errorf("recording %s data: %s", key, err)
+		}
+	}
 	return nil
 }
 
@@ -135,7 +211,7 @@ func freshen(c appengine.Context, key string) error {
 	if err != nil {
 		return err
 	}
-	if time.Now().Unix() < fresh + int64(s.Refresh.Seconds()) {
+	if time.Now().Unix() < fresh+int64(s.Refresh.Seconds()) {
 		return nil
 	}
 
@@ -160,6 +236,77 @@ func freshenAll(c appengine.Context) error {
 	return nil
 }
 
+// dataStamp returns a hash of what is shown from the cached data of the
+// sources a display uses.  It changes only when there is new data to
+// show, unlike the _fresh times, which change whenever the same data is
+// fetched again.
+func dataStamp(c appengine.Context, d *Display) (string, error) {
+	keys := d.sources()
+	items, err := memcache.GetMulti(c, keys)
+	if err != nil {
+		return "", err
+	}
+	return stamp(keys, items, c.Warningf), nil
+}
+
+// stamp hashes the items with the given keys.  A missing item counts
+// too, since it is missing from the page as well.  Data that can't be
+// parsed is passed to logf and hashed as it is.
+func stamp(keys []string, items map[string]*memcache.Item, logf func(format string, args ...interface{})) string {
+	sort.Strings(keys)
+	h := sha1.New()
+	for _, key := range keys {
+		item, ok := items[key]
+		if !ok {
+			fmt.Fprintf(h, "%s -\n", key)
+			continue
+		}
+		data := item.Value
+		if shown := Sources[key].Shown; shown != nil {
+			v, err := shown(data)
+			if err == nil {
+				data, err = json.Marshal(v)
+			}
+			if err != nil {
+				logf("%s: %s", key, err)
+				data = item.Value
+			}
+		}
+		fmt.Fprintf(h, "%s %d\n", key, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func stampHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := Displays[r.FormValue("display")]
+	if !ok {
+		http.Error(w, "Unknown display", http.StatusNotFound)
+		return
+	}
+	c := appengine.NewContext(r)
+	if err := freshenAll(c); err != nil {
+		c.Errorf("%s", err)
+	}
+	stamp, err := dataStamp(c, d)
+	if err != nil {
+		c.Errorf("%s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, stamp)
+}
+
+// nextBusHandler serves the bus predictions alone, for the page to
+// update in place.
+func nextBusHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	NextBus(w, c)
+}
+
 func freshenAllHandler(w http.ResponseWriter, r *http.Request) {
 	c := appengine.NewContext(r)
 	if err := freshenAll(c); err != nil {
@@ -172,6 +319,8 @@ func freshenAllHandler(w http.ResponseWriter, r *http.Request) {
 
 func init() {
 	http.HandleFunc("/freshen", freshenAllHandler)
+	http.HandleFunc("/stamp", stampHandler)
+	http.HandleFunc("/nextbus", nextBusHandler)
 	http.HandleFunc("/_ah/warmup", freshenAllHandler)
 
 	for key, _ := range Sources {
@@ -0,0 +1,106 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"appengine/memcache"
+)
+
+func TestStamp(t *testing.T) {
+	// These sources aren't parsed.
+	keys := []string{"a", "b"}
+	items := map[string]*memcache.Item{
+		"a": &memcache.Item{Key: "a", Value: []byte("rain")},
+		"b": &memcache.Item{Key: "b", Value: []byte("fog")},
+	}
+	before := stamp(keys, items, t.Logf)
+	if got := stamp([]string{"b", "a"}, items, t.Logf); got != before {
+		t.Errorf("stamp depends on the order of keys: %s, then %s", before, got)
+	}
+
+	// Fetching the same data again, or fetching another source, must
+	// not reload the page.
+	items["b"] = &memcache.Item{Key: "b", Value: []byte("fog")}
+	items["nextbus"] = &memcache.Item{Key: "nextbus", Value: []byte("5 minutes")}
+	if got := stamp(keys, items, t.Logf); got != before {
+		t.Errorf("stamp changed from %s to %s with the same data", before, got)
+	}
+
+	items["b"] = &memcache.Item{Key: "b", Value: []byte("sun")}
+	changed := stamp(keys, items, t.Logf)
+	if changed == before {
+		t.Errorf("stamp didn't change with new data")
+	}
+	delete(items, "b")
+	if got := stamp(keys, items, t.Logf); got == changed || got == before {
+		t.Errorf("stamp didn't change when data expired")
+	}
+}
+
+// The alerts feed says when it was generated, which changes each time it
+// is fetched, but only the alerts themselves are shown.
+func TestStampShown(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/alerts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"alerts"}
+	alerts := func(data string) map[string]*memcache.Item {
+		return map[string]*memcache.Item{"alerts": &memcache.Item{Key: "alerts", Value: []byte(data)}}
+	}
+	feed := string(data)
+	before := stamp(keys, alerts(feed), t.Errorf)
+	regenerated := strings.Replace(feed,
+		"<updated>2012-09-25T02:12:00-07:00</updated>\n<author>",
+		"<updated>2012-09-25T02:14:00-07:00</updated>\n<author>", 1)
+	if regenerated == feed {
+		t.Fatal("feed's updated time not found")
+	}
+	if got := stamp(keys, alerts(regenerated), t.Errorf); got != before {
+		t.Errorf("stamp changed when the feed was regenerated")
+	}
+	extended := strings.Replace(feed, "Red Flag Warning", "Red Flag Warning Extended", -1)
+	if got := stamp(keys, alerts(extended), t.Errorf); got == before {
+		t.Errorf("stamp didn't change with a new alert")
+	}
+}
+
+func TestSources(t *testing.T) {
+	cases := []struct {
+		d    Display
+		want string
+	}{
+		{Display{}, "alerts forecast"},
+		{Display{Fields: []string{"temp"}, ChartHours: 24}, "alerts forecast conditions weathergov"},
+		{Display{ForecastSource: "weathergov", ChartHours: 24}, "alerts weathergov"},
+		{Display{Panels: []string{"worldclocks", "discussion"}}, "alerts forecast discussion"},
+	}
+	for _, tt := range cases {
+		if got := strings.Join(tt.d.sources(), " "); got != tt.want {
+			t.Errorf("sources of %+v: got %q, want %q", tt.d, got, tt.want)
+		}
+	}
+	for name, d := range Displays {
+		for _, key := range d.sources() {
+			if _, ok := Sources[key]; !ok {
+				t.Errorf("display %q uses unknown source %q", name, key)
+			}
+		}
+	}
+}
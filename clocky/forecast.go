@@ -0,0 +1,302 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template" // TODO: Switch to Go 1's html/template.
+	"time"
+
+	"appengine"
+	"appengine/memcache"
+
+	"units"
+)
+
+// Period is one period of a forecast, such as "Tuesday night".
+// Measurements that aren't forecast for it are nil.
+type Period struct {
+	Name  string
+	Start time.Time
+
+	// A day has a high and a night has a low.  An hour of an hourly
+	// forecast has a temperature instead.
+	High, Low, Temp *units.Temperature
+	// PoP is the probability of precipitation, in percent.
+	PoP *float64
+	// Summary is a few words about the weather, such as "Patchy
+	// Fog", and Icon is the URL of NWS's picture of it.
+	Summary, Icon string
+	// Text is the worded forecast.
+	Text string
+}
+
+// dwml is the part of a DWML document that is used.  Each parameter's
+// values are for the times in the time layout it names.
+type dwml struct {
+	Data []struct {
+		Type       string `xml:"type,attr"`
+		TimeLayout []struct {
+			LayoutKey      string `xml:"layout-key"`
+			StartValidTime []struct {
+				PeriodName string `xml:"period-name,attr"`
+				Time       string `xml:",chardata"`
+			} `xml:"start-valid-time"`
+		} `xml:"time-layout"`
+		Parameters struct {
+			Temperature []struct {
+				Type       string   `xml:"type,attr"`
+				Units      string   `xml:"units,attr"`
+				TimeLayout string   `xml:"time-layout,attr"`
+				Value      []string `xml:"value"`
+			} `xml:"temperature"`
+			PoP struct {
+				TimeLayout string   `xml:"time-layout,attr"`
+				Value      []string `xml:"value"`
+			} `xml:"probability-of-precipitation"`
+			Weather struct {
+				TimeLayout string `xml:"time-layout,attr"`
+				Conditions []struct {
+					Summary string `xml:"weather-summary,attr"`
+				} `xml:"weather-conditions"`
+			} `xml:"weather"`
+			Icon struct {
+				TimeLayout string   `xml:"time-layout,attr"`
+				Link       []string `xml:"icon-link"`
+			} `xml:"conditions-icon"`
+			WordedForecast struct {
+				TimeLayout string   `xml:"time-layout,attr"`
+				Text       []string `xml:"text"`
+			} `xml:"wordedForecast"`
+		} `xml:"parameters"`
+	} `xml:"data"`
+}
+
+// ParseDWML parses the forecast in a NWS MapClick DWML document.  The
+// periods are those of the worded forecast, and the other parameters are
+// joined onto them by their start times.
+func ParseDWML(r io.Reader) ([]Period, error) {
+	var doc dwml
+	p := xml.NewDecoder(r)
+	// NWS serves XML in ISO-8859-1 for no reason; the data is really ASCII.
+	p.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := p.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for _, d := range doc.Data {
+		if d.Type != "forecast" {
+			continue
+		}
+		layouts := make(map[string][]time.Time)
+		names := make(map[string][]string)
+		for _, tl := range d.TimeLayout {
+			for _, svt := range tl.StartValidTime {
+				t, err := time.Parse(time.RFC3339, strings.TrimSpace(svt.Time))
+				if err != nil {
+					return nil, fmt.Errorf("dwml: %s", err)
+				}
+				layouts[tl.LayoutKey] = append(layouts[tl.LayoutKey], t)
+				names[tl.LayoutKey] = append(names[tl.LayoutKey], svt.PeriodName)
+			}
+		}
+
+		params := d.Parameters
+		base := params.WordedForecast.TimeLayout
+		if base == "" {
+			base = params.Weather.TimeLayout
+		}
+		if layouts[base] == nil {
+			return nil, fmt.Errorf("dwml: no time layout %q", base)
+		}
+		periods := make([]Period, len(layouts[base]))
+		index := make(map[int64]int)
+		for i, t := range layouts[base] {
+			periods[i] = Period{Name: periodName(names[base][i]), Start: t}
+			index[t.Unix()] = i
+		}
+		// join calls f with the period for each value in a layout.
+		join := func(layout string, n int, f func(p *Period, i int)) {
+			for i, t := range layouts[layout] {
+				if j, ok := index[t.Unix()]; ok && i < n {
+					f(&periods[j], i)
+				}
+			}
+		}
+
+		for _, temp := range params.Temperature {
+			scale := units.Celsius
+			if temp.Units == "Fahrenheit" {
+				scale = units.Fahrenheit
+			}
+			values := temp.Value
+			join(temp.TimeLayout, len(values), func(p *Period, i int) {
+				n, err := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+				if err != nil {
+					return
+				}
+				t := scale.Of(n)
+				switch temp.Type {
+				case "maximum":
+					p.High = &t
+				case "minimum":
+					p.Low = &t
+				}
+			})
+		}
+		join(params.PoP.TimeLayout, len(params.PoP.Value), func(p *Period, i int) {
+			n, err := strconv.ParseFloat(strings.TrimSpace(params.PoP.Value[i]), 64)
+			if err == nil {
+				p.PoP = &n
+			}
+		})
+		join(params.Weather.TimeLayout, len(params.Weather.Conditions), func(p *Period, i int) {
+			p.Summary = params.Weather.Conditions[i].Summary
+		})
+		join(params.Icon.TimeLayout, len(params.Icon.Link), func(p *Period, i int) {
+			p.Icon = strings.TrimSpace(params.Icon.Link[i])
+		})
+		join(params.WordedForecast.TimeLayout, len(params.WordedForecast.Text), func(p *Period, i int) {
+			p.Text = strings.TrimSpace(params.WordedForecast.Text[i])
+		})
+		return periods, nil
+	}
+	return nil, fmt.Errorf("dwml: no forecast")
+}
+
+// periodName uses sentence case for the names of forecast periods, such
+// as "Saturday night", which NWS capitalizes.
+func periodName(name string) string {
+	name = strings.Replace(name, " Morning", " morning", -1)
+	name = strings.Replace(name, " Afternoon", " afternoon", -1)
+	name = strings.Replace(name, " Night", " night", -1)
+	return name
+}
+
+// parseForecast parses the forecast periods from a source.
+func parseForecast(source string, data []byte) ([]Period, error) {
+	if source == "weathergov" {
+		periods, _, err := ParseWeatherGov(data)
+		return periods, err
+	}
+	return ParseDWML(strings.NewReader(string(data)))
+}
+
+// Forecast shows a row for each of the next few periods of the NWS
+// forecast, and their worded forecasts if the display wants them.  It is
+// fetched in metric units, and converted to the display's.
+func Forecast(w io.Writer, c appengine.Context, d *Display) {
+	source := d.forecastSource()
+	item, err := memcache.Get(c, source)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	periods, err := parseForecast(source, item.Value)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+
+	n := 6
+	if d.ForecastText {
+		n = 4
+	}
+	if d.ChartHours > 0 {
+		n -= 2 // Leave room for the chart.
+	}
+	if len(periods) > n {
+		periods = periods[:n]
+	}
+	io.WriteString(w, `<div class=smaller style="text-align: left">`)
+	for _, p := range periods {
+		forecastRow(w, d, p)
+		if d.ForecastText && p.Text != "" {
+			io.WriteString(w, `<div style="margin-bottom: 8px">`)
+			forecastText(w, convertForecast(p.Text, d.Units))
+			io.WriteString(w, `</div>`)
+		}
+	}
+	io.WriteString(w, `</div>`)
+}
+
+// forecastRow shows a period's name, icon, high or low, and chance of
+// rain.
+func forecastRow(w io.Writer, d *Display, p Period) {
+	io.WriteString(w, `<div class=period>`)
+	if p.Icon != "" {
+		io.WriteString(w, `<img class=icon src="`)
+		template.HTMLEscape(w, []byte(p.Icon))
+		io.WriteString(w, `" alt="`)
+		template.HTMLEscape(w, []byte(p.Summary))
+		io.WriteString(w, `">`)
+	}
+	io.WriteString(w, `<span class=header>`)
+	template.HTMLEscape(w, []byte(p.Name))
+	io.WriteString(w, `</span>`)
+	if p.High != nil {
+		fmt.Fprintf(w, ` high %.0f°`, p.High.In(d.Units.Temperature))
+	}
+	if p.Low != nil {
+		fmt.Fprintf(w, ` low %.0f°`, p.Low.In(d.Units.Temperature))
+	}
+	if p.PoP != nil && *p.PoP > 0 {
+		fmt.Fprintf(w, `, %.0f%% rain`, *p.PoP)
+	}
+	if !d.ForecastText && p.Summary != "" {
+		io.WriteString(w, `, `)
+		template.HTMLEscape(w, []byte(strings.ToLower(p.Summary)))
+	}
+	io.WriteString(w, `</div>`)
+}
+
+var (
+	nbspRegexp   = regexp.MustCompile(` [0-9]+\.`)
+	thinspRegexp = regexp.MustCompile(`[0-9] (am|pm|km/|m/|mph|kn\b)`)
+)
+
+// forecastText shows a worded forecast, keeping numbers with what
+// follows them.
+func forecastText(w io.Writer, text string) {
+	text = strings.Replace(text, "/", "/\u2060", -1)
+	spaceSubs := make(map[int]string)
+	matches := nbspRegexp.FindAllStringIndex(text, -1)
+	if len(matches) > 0 {
+		for i := 0; i < len(matches[0]); i += 2 {
+			spaceSubs[matches[0][i]] = "&nbsp;"
+		}
+	}
+	matches = thinspRegexp.FindAllStringIndex(text, -1)
+	if len(matches) > 0 {
+		for i := 0; i < len(matches[0]); i += 2 {
+			spaceSubs[matches[0][i]+1] = `<span style="white-space: nowrap">&thinsp;</span>`
+		}
+	}
+	for i, ch := range text {
+		sub, ok := spaceSubs[i]
+		if ok {
+			io.WriteString(w, sub)
+		} else {
+			io.WriteString(w, string(ch))
+		}
+	}
+}
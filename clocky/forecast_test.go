@@ -0,0 +1,141 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"units"
+)
+
+func TestParseDWML(t *testing.T) {
+	f, err := os.Open("testdata/MapClick.php.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	periods, err := ParseDWML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 14 {
+		t.Fatalf("got %d periods, want 14", len(periods))
+	}
+
+	temp := func(t *units.Temperature) string {
+		if t == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("%g", float64(*t))
+	}
+	pst := time.FixedZone("PST", -8*3600)
+	cases := []struct {
+		i             int
+		name          string
+		start         time.Time
+		high, low     string
+		summary, icon string
+		text          string
+	}{
+		{0, "Overnight", time.Date(2011, 12, 31, 0, 0, 0, 0, pst), "nil", "9",
+			"Patchy Fog", "nfg.jpg", "Patchy fog. Otherwise,"},
+		{1, "Saturday", time.Date(2011, 12, 31, 6, 0, 0, 0, pst), "16", "nil",
+			"Patchy Fog", "sctfg.jpg", "Patchy fog before 10am."},
+		{2, "Saturday night", time.Date(2011, 12, 31, 18, 0, 0, 0, pst), "nil", "9",
+			"Mostly Clear", "nfew.jpg", "Mostly clear, with a low around 9."},
+		{13, "Friday", time.Date(2012, 1, 6, 6, 0, 0, 0, pst), "16", "nil",
+			"Slight Chc Rain", "ra.jpg", "A slight chance of rain."},
+	}
+	for _, tt := range cases {
+		p := periods[tt.i]
+		if p.Name != tt.name || !p.Start.Equal(tt.start) {
+			t.Errorf("period %d is %q at %v, want %q at %v", tt.i, p.Name, p.Start, tt.name, tt.start)
+		}
+		if temp(p.High) != tt.high || temp(p.Low) != tt.low {
+			t.Errorf("%s: high %s low %s, want high %s low %s",
+				p.Name, temp(p.High), temp(p.Low), tt.high, tt.low)
+		}
+		if p.PoP != nil {
+			t.Errorf("%s: PoP %g, want nil", p.Name, *p.PoP)
+		}
+		if p.Summary != tt.summary || !strings.HasSuffix(p.Icon, "/"+tt.icon) {
+			t.Errorf("%s: summary %q icon %q, want %q and %q",
+				p.Name, p.Summary, p.Icon, tt.summary, tt.icon)
+		}
+		if !strings.HasPrefix(p.Text, tt.text) {
+			t.Errorf("%s: text %q, want prefix %q", p.Name, p.Text, tt.text)
+		}
+	}
+}
+
+// The chance of rain is joined to the periods by start time, since it
+// starts later than they do.
+func TestParseDWMLPoP(t *testing.T) {
+	f, err := os.Open("testdata/MapClick-rain.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	periods, err := ParseDWML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pop := func(p *float64) string {
+		if p == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("%g", *p)
+	}
+	want := []struct {
+		name, pop, summary string
+	}{
+		{"Tonight", "nil", "Rain Likely"},
+		{"Wednesday", "80", "Rain"},
+		{"Wednesday night", "nil", "Mostly Cloudy"},
+		{"Thanksgiving Day", "20", "Slight Chc Showers"},
+	}
+	if len(periods) != len(want) {
+		t.Fatalf("got %d periods, want %d", len(periods), len(want))
+	}
+	for i, w := range want {
+		p := periods[i]
+		if p.Name != w.name || pop(p.PoP) != w.pop || p.Summary != w.summary {
+			t.Errorf("period %d is %q, PoP %s, %q; want %q, PoP %s, %q",
+				i, p.Name, pop(p.PoP), p.Summary, w.name, w.pop, w.summary)
+		}
+	}
+	if p := periods[3]; p.High == nil || *p.High != 14 || p.Low != nil {
+		t.Errorf("%s: high %v low %v, want high 14", p.Name, p.High, p.Low)
+	}
+}
+
+func TestForecastRow(t *testing.T) {
+	high := units.Temperature(20)
+	pop := 30.0
+	p := Period{Name: "Tuesday", High: &high, PoP: &pop, Summary: "Chance Showers",
+		Icon: "http://forecast.weather.gov/images/wtf/shra30.jpg"}
+	var b bytes.Buffer
+	forecastRow(&b, &Display{Units: units.Imperial}, p)
+	want := `<div class=period><img class=icon src="http://forecast.weather.gov/images/wtf/shra30.jpg" alt="Chance Showers">` +
+		`<span class=header>Tuesday</span> high 68°, 30% rain, chance showers</div>`
+	if b.String() != want {
+		t.Errorf("forecastRow:\n got %s\nwant %s", b.String(), want)
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"appengine"
+	"appengine/datastore"
+
+	"units"
+)
+
+// Observations are kept in the datastore, so that trends can be shown.
+// Only the stations that could be shown are kept, rather than the
+// hundreds in each copy of latest_obs.txt, and only for keepHistory.
+
+// keepHistory is how long observations are kept.  It is longer than the
+// trends and quality control look back, so that /history has something
+// to show beyond them.
+const keepHistory = 7 * 24 * time.Hour
+
+// pruneBatch is how many old observations are deleted at a time.
+const pruneBatch = 500
+
+// reading is how an Observation is stored.  The datastore can't store
+// nil, so Fields are the NDBC names of the measurements that were
+// reported, and Values are their values.
+type reading struct {
+	Station  string
+	Lat, Lng float64
+	Time     time.Time
+	Fields   []string
+	Values   []float64
+}
+
+// measurements maps the NDBC names of an observation's measurements to
+// where they are kept.
+func measurements(o *Observation) map[string]**float64 {
+	return map[string]**float64{
+		"WDIR": &o.WindDir,
+		"WSPD": &o.WindSpeed,
+		"GST":  &o.Gust,
+		"WVHT": &o.WaveHeight,
+		"DPD":  &o.DominantPeriod,
+		"APD":  &o.AveragePeriod,
+		"MWD":  &o.WaveDir,
+		"PRES": &o.Pressure,
+		"PTDY": &o.Tendency,
+		"ATMP": &o.AirTemp,
+		"WTMP": &o.WaterTemp,
+		"DEWP": &o.DewPoint,
+		"VIS":  &o.Visibility,
+		"TIDE": &o.Tide,
+	}
+}
+
+// observationKey identifies an observation by its station and time, in
+// the datastore and in the rejections cached by quality control.
+func observationKey(o Observation) string {
+	return fmt.Sprintf("%s %d", o.Station, o.Time.Unix())
+}
+
+func toReading(o Observation) *reading {
+	r := &reading{Station: o.Station, Lat: o.Lat, Lng: o.Lng, Time: o.Time}
+	for name, m := range measurements(&o) {
+		if *m != nil {
+			r.Fields = append(r.Fields, name)
+			r.Values = append(r.Values, **m)
+		}
+	}
+	return r
+}
+
+func (r *reading) observation() Observation {
+	o := Observation{Station: r.Station, Lat: r.Lat, Lng: r.Lng, Time: r.Time.UTC()}
+	m := measurements(&o)
+	for i, name := range r.Fields {
+		if p, ok := m[name]; ok && i < len(r.Values) {
+			v := r.Values[i]
+			*p = &v
+		}
+	}
+	return o
+}
+
+// kept reports whether a station's observations are kept: those of the
+// stations configured for any display, and of those near enough to be
+// used in their place.
+func kept(o Observation) bool {
+	for _, d := range Displays {
+		for _, s := range d.Stations {
+			if s == o.Station {
+				return true
+			}
+		}
+	}
+	return distance(Lat, Lng, o.Lat, o.Lng) <= maxStationDistance
+}
+
+// shownObservations returns the observations in a copy of latest_obs.txt
+// from the stations that are kept, which are the only ones that could be
+// shown.
+func shownObservations(data []byte) (interface{}, error) {
+	obs, err := ParseObservations(bytes.NewReader(data), func(string, ...interface{}) {})
+	if err != nil {
+		return nil, err
+	}
+	var shown []Observation
+	for _, o := range obs {
+		if kept(o) {
+			shown = append(shown, o)
+		}
+	}
+	return shown, nil
+}
+
+// recordObservations stores the observations in a fetched copy of
+// latest_obs.txt, after quality control.  Only the stations that are
+// kept are stored; the others are dropped.  Each observation is keyed by
+// its station and time, so storing one again, as happens until the
+// station next reports, only overwrites it.
+func recordObservations(c appengine.Context, data []byte) error {
+	obs, err := ParseObservations(bytes.NewReader(data), c.Warningf)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	history, err := allHistory(c, now.Add(-stuckPeriod-spikeWindow))
+	if err != nil {
+		return err
+	}
+	var keys []*datastore.Key
+	var readings []*reading
+	rejected := make(map[string][]string)
+	for _, o := range obs {
+		if !kept(o) {
+			continue
+		}
+		k := observationKey(o)
+		for name, reason := range check(o, history[o.Station]) {
+			c.Warningf("rejected %s %s at %s: %s",
+				o.Station, name, o.Time.Format(time.RFC3339), reason)
+			rejected[k] = append(rejected[k], name)
+		}
+		o.reject(rejected[k])
+		keys = append(keys, datastore.NewKey(c, "Observation", k, 0, nil))
+		readings = append(readings, toReading(o))
+	}
+	if err := cacheRejections(c, rejected); err != nil {
+		return err
+	}
+	if _, err := datastore.PutMulti(c, keys, readings); err != nil {
+		return err
+	}
+	c.Infof("stored %d observations", len(keys))
+	return nil
+}
+
+// allHistory returns every station's observations since a time, oldest
+// first, by station.
+func allHistory(c appengine.Context, since time.Time) (map[string][]Observation, error) {
+	q := datastore.NewQuery("Observation").
+		Filter("Time >=", since).
+		Order("Time")
+	var readings []*reading
+	if _, err := q.GetAll(c, &readings); err != nil {
+		return nil, err
+	}
+	history := make(map[string][]Observation)
+	for _, r := range readings {
+		history[r.Station] = append(history[r.Station], r.observation())
+	}
+	return history, nil
+}
+
+// History returns a station's observations since a time, oldest first.
+func History(c appengine.Context, station string, since time.Time) ([]Observation, error) {
+	q := datastore.NewQuery("Observation").
+		Filter("Station =", station).
+		Filter("Time >=", since).
+		Order("Time")
+	var readings []*reading
+	if _, err := q.GetAll(c, &readings); err != nil {
+		return nil, err
+	}
+	obs := make([]Observation, len(readings))
+	for i, r := range readings {
+		obs[i] = r.observation()
+	}
+	return obs, nil
+}
+
+// prune deletes the observations older than keepHistory.
+func prune(c appengine.Context, now time.Time) error {
+	q := datastore.NewQuery("Observation").
+		Filter("Time <", now.Add(-keepHistory)).
+		KeysOnly().
+		Limit(pruneBatch)
+	total := 0
+	for {
+		keys, err := q.GetAll(c, nil)
+		if err != nil {
+			return err
+		}
+		if err := datastore.DeleteMulti(c, keys); err != nil {
+			return err
+		}
+		total += len(keys)
+		if len(keys) < pruneBatch {
+			break
+		}
+	}
+	c.Infof("pruned %d observations", total)
+	return nil
+}
+
+// pruneHandler is run by cron.
+func pruneHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+	if err := prune(c, time.Now()); err != nil {
+		c.Errorf("%s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
+
+// historyHandler serves a station's history as JSON, as in
+// /history?station=FTPC1&hours=24.  Missing measurements are null.  Only
+// kept stations have any, for up to keepHistory.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+	station := r.FormValue("station")
+	if station == "" {
+		http.Error(w, "No station", http.StatusBadRequest)
+		return
+	}
+	hours := 24
+	if s := r.FormValue("hours"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "Bad hours", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+	obs, err := History(c, station, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		c.Errorf("%s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if obs == nil {
+		obs = []Observation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obs); err != nil {
+		c.Errorf("%s", err)
+	}
+}
+
+func init() {
+	http.HandleFunc("/history", historyHandler)
+	http.HandleFunc("/prune", pruneHandler)
+}
+
+// Size of a sparkline, in pixels.
+const sparkWidth, sparkHeight = 96, 20
+
+// trendPeriod is how far back the sparklines go.
+const trendPeriod = 24 * time.Hour
+
+// sparkline draws a measurement over the trend period before now, scaled
+// to fill the height.  Nothing is drawn with fewer than two values.
+func sparkline(w io.Writer, obs []Observation, now time.Time, f func(Observation) *float64) {
+	var times []time.Time
+	var values []float64
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, o := range obs {
+		if v := f(o); v != nil {
+			times = append(times, o.Time)
+			values = append(values, *v)
+			lo, hi = math.Min(lo, *v), math.Max(hi, *v)
+		}
+	}
+	if len(values) < 2 {
+		return
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	since := now.Add(-trendPeriod)
+	fmt.Fprintf(w, `<svg width=%d height=%d viewBox="0 0 %d %d" style="vertical-align: middle">`,
+		sparkWidth, sparkHeight, sparkWidth, sparkHeight)
+	io.WriteString(w, `<polyline fill=none stroke=currentColor stroke-width=1.5 points="`)
+	for i, t := range times {
+		x := sparkWidth * t.Sub(since).Seconds() / trendPeriod.Seconds()
+		y := 1 + (sparkHeight-2)*(hi-values[i])/(hi-lo)
+		fmt.Fprintf(w, "%.1f,%.1f ", x, y)
+	}
+	io.WriteString(w, `" /></svg>`)
+}
+
+// highLow returns the highest and lowest of a measurement since a time,
+// or nils if there are none.
+func highLow(obs []Observation, since time.Time, f func(Observation) *float64) (high, low *float64) {
+	for _, o := range obs {
+		v := f(o)
+		if v == nil || o.Time.Before(since) {
+			continue
+		}
+		if high == nil || *v > *high {
+			high = v
+		}
+		if low == nil || *v < *low {
+			low = v
+		}
+	}
+	return high, low
+}
+
+// trends shows sparklines of the temperature, wind speed and pressure
+// over the last day, from the stations that supplied the current values,
+// with the high and low temperature so far today.
+func trends(w io.Writer, c appengine.Context, d *Display, now time.Time, temp, wind, pres string) {
+	histories := make(map[string][]Observation)
+	for _, s := range []string{temp, wind, pres} {
+		if _, ok := histories[s]; s == "" || ok {
+			continue
+		}
+		obs, err := History(c, s, now.Add(-trendPeriod))
+		if err != nil {
+			c.Errorf("%s", err)
+		}
+		histories[s] = obs
+	}
+
+	var b bytes.Buffer
+	if obs := histories[temp]; len(obs) > 0 {
+		io.WriteString(&b, `temp `)
+		sparkline(&b, obs, now, func(o Observation) *float64 { return o.AirTemp })
+		if location, err := time.LoadLocation(d.Zone); err == nil {
+			y, m, day := now.In(location).Date()
+			midnight := time.Date(y, m, day, 0, 0, 0, 0, location)
+			high, low := highLow(obs, midnight, func(o Observation) *float64 { return o.AirTemp })
+			if high != nil {
+				fmt.Fprintf(&b, ` high %s low %s`,
+					d.temperature(units.Temperature(*high)), d.temperature(units.Temperature(*low)))
+			}
+		}
+		io.WriteString(&b, ` `)
+	}
+	if obs := histories[wind]; len(obs) > 0 {
+		io.WriteString(&b, `wind `)
+		sparkline(&b, obs, now, func(o Observation) *float64 { return o.WindSpeed })
+		io.WriteString(&b, ` `)
+	}
+	if obs := histories[pres]; len(obs) > 0 {
+		io.WriteString(&b, `pressure `)
+		sparkline(&b, obs, now, func(o Observation) *float64 { return o.Pressure })
+	}
+	if b.Len() > 0 {
+		io.WriteString(w, `<div class=smaller>`)
+		w.Write(b.Bytes())
+		io.WriteString(w, `</div>`)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReading(t *testing.T) {
+	f, err := os.Open("testdata/latest_obs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	obs, err := ParseObservations(f, t.Errorf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, o := range obs {
+		if got := toReading(o).observation(); !reflect.DeepEqual(got, o) {
+			t.Errorf("stored and loaded %+v, got %+v", o, got)
+		}
+		if kept(o) {
+			n++
+		}
+	}
+	if n == 0 || n == len(obs) {
+		t.Errorf("kept %d of %d stations", n, len(obs))
+	}
+}
+
+// Pruning mustn't delete what the trends and quality control read.
+func TestKeepHistory(t *testing.T) {
+	for _, period := range []time.Duration{trendPeriod, stuckPeriod + spikeWindow} {
+		if keepHistory < period {
+			t.Errorf("keeping history %s, but reading back %s", keepHistory, period)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	now := time.Date(2012, 3, 1, 12, 0, 0, 0, time.UTC)
+	temp := func(v float64) *float64 { return &v }
+	obs := []Observation{
+		{Time: now.Add(-24 * time.Hour), AirTemp: temp(10)},
+		{Time: now.Add(-12 * time.Hour), AirTemp: temp(14)},
+		{Time: now.Add(-6 * time.Hour)},
+		{Time: now, AirTemp: temp(12)},
+	}
+	f := func(o Observation) *float64 { return o.AirTemp }
+	var b bytes.Buffer
+	sparkline(&b, obs, now, f)
+	if want := `points="0.0,19.0 48.0,1.0 96.0,10.0 "`; !strings.Contains(b.String(), want) {
+		t.Errorf("sparkline = %s, want %s", b.String(), want)
+	}
+
+	b.Reset()
+	sparkline(&b, obs[:1], now, f)
+	if b.Len() != 0 {
+		t.Errorf("sparkline of one value = %s, want nothing", b.String())
+	}
+
+	high, low := highLow(obs, now.Add(-13*time.Hour), f)
+	if high == nil || *high != 14 || low == nil || *low != 12 {
+		t.Errorf("highLow = %v, %v, want 14, 12", high, low)
+	}
+	if high, low := highLow(obs, now.Add(time.Hour), f); high != nil || low != nil {
+		t.Errorf("highLow of nothing = %v, %v, want nil, nil", high, low)
+	}
+}
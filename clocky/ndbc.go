@@ -0,0 +1,150 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Observation is a station's latest report in the NDBC latest_obs.txt
+// file.  Measurements the station did not report are nil.  Units are as
+// in the file: see http://www.ndbc.noaa.gov/measdes.shtml
+type Observation struct {
+	Station  string
+	Lat, Lng float64
+	Time     time.Time // UTC
+
+	WindDir        *float64 // WDIR: degrees true the wind is from
+	WindSpeed      *float64 // WSPD: m/s, averaged over 2 or 8 minutes
+	Gust           *float64 // GST: m/s, peak 5 or 8 second gust
+	WaveHeight     *float64 // WVHT: significant wave height in m
+	DominantPeriod *float64 // DPD: dominant wave period in s
+	AveragePeriod  *float64 // APD: average wave period in s
+	WaveDir        *float64 // MWD: degrees true the waves are from
+	Pressure       *float64 // PRES: sea level pressure in hPa
+	Tendency       *float64 // PTDY: pressure change over 3 hours in hPa
+	AirTemp        *float64 // ATMP: °C
+	WaterTemp      *float64 // WTMP: sea surface temperature in °C
+	DewPoint       *float64 // DEWP: °C
+	Visibility     *float64 // VIS: nautical miles
+	Tide           *float64 // TIDE: water level in ft above or below MLLW
+}
+
+// ParseObservations parses the NDBC latest_obs.txt file.  Columns are
+// found by their names in the header row, not their positions, and "MM"
+// is a missing measurement.  The file has the odd ragged or garbled row,
+// so rows that can't be parsed are passed to logf and skipped, rather
+// than losing every other station's report with them.
+func ParseObservations(r io.Reader, logf func(format string, args ...interface{})) ([]Observation, error) {
+	var obs []Observation
+	var columns map[string]int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// The first header row has the column names;
+			// the second has the units.
+			if columns == nil {
+				fields[0] = strings.TrimPrefix(fields[0], "#")
+				columns = make(map[string]int)
+				for i, f := range fields {
+					columns[f] = i
+				}
+			}
+			continue
+		}
+		if columns == nil {
+			return nil, fmt.Errorf("ndbc: no header before %q", line)
+		}
+		if len(fields) != len(columns) {
+			logf("ndbc: skipping row with %d fields, want %d: %q",
+				len(fields), len(columns), line)
+			continue
+		}
+		o, err := parseObservation(fields, columns)
+		if err != nil {
+			logf("ndbc: skipping row with %s: %q", err, line)
+			continue
+		}
+		obs = append(obs, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
+
+func parseObservation(fields []string, columns map[string]int) (Observation, error) {
+	var err error
+	field := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return fields[i]
+		}
+		return "MM"
+	}
+	number := func(name string) *float64 {
+		s := field(name)
+		if s == "MM" || err != nil {
+			return nil
+		}
+		n, e := strconv.ParseFloat(s, 64)
+		if e != nil {
+			err = fmt.Errorf("bad %s %q", name, s)
+			return nil
+		}
+		return &n
+	}
+	integer := func(name string) int {
+		n, e := strconv.Atoi(field(name))
+		if e != nil && err == nil {
+			err = fmt.Errorf("bad %s %q", name, field(name))
+		}
+		return n
+	}
+
+	o := Observation{Station: field("STN")}
+	if lat := number("LAT"); lat != nil {
+		o.Lat = *lat
+	}
+	if lng := number("LON"); lng != nil {
+		o.Lng = *lng
+	}
+	o.Time = time.Date(integer("YYYY"), time.Month(integer("MM")), integer("DD"),
+		integer("hh"), integer("mm"), 0, 0, time.UTC)
+	o.WindDir = number("WDIR")
+	o.WindSpeed = number("WSPD")
+	o.Gust = number("GST")
+	o.WaveHeight = number("WVHT")
+	o.DominantPeriod = number("DPD")
+	o.AveragePeriod = number("APD")
+	o.WaveDir = number("MWD")
+	o.Pressure = number("PRES")
+	o.Tendency = number("PTDY")
+	o.AirTemp = number("ATMP")
+	o.WaterTemp = number("WTMP")
+	o.DewPoint = number("DEWP")
+	o.Visibility = number("VIS")
+	o.Tide = number("TIDE")
+	return o, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseObservations(t *testing.T) {
+	f, err := os.Open("testdata/latest_obs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	obs, err := ParseObservations(f, t.Errorf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs) != 642 {
+		t.Errorf("got %d observations, want 642", len(obs))
+	}
+
+	stations := make(map[string]Observation)
+	for _, o := range obs {
+		stations[o.Station] = o
+	}
+	n := func(f float64) *float64 { return &f }
+	cases := []struct {
+		station string
+		field   string
+		got     func(o Observation) *float64
+		want    *float64
+	}{
+		{"FTPC1", "WDIR", func(o Observation) *float64 { return o.WindDir }, n(190)},
+		{"FTPC1", "WSPD", func(o Observation) *float64 { return o.WindSpeed }, n(1.5)},
+		{"FTPC1", "GST", func(o Observation) *float64 { return o.Gust }, n(1.5)},
+		{"FTPC1", "WVHT", func(o Observation) *float64 { return o.WaveHeight }, nil},
+		{"FTPC1", "PRES", func(o Observation) *float64 { return o.Pressure }, n(1021.7)},
+		{"FTPC1", "PTDY", func(o Observation) *float64 { return o.Tendency }, n(-0.4)},
+		{"FTPC1", "ATMP", func(o Observation) *float64 { return o.AirTemp }, n(7.8)},
+		{"FTPC1", "WTMP", func(o Observation) *float64 { return o.WaterTemp }, n(10.7)},
+		{"FTPC1", "DEWP", func(o Observation) *float64 { return o.DewPoint }, nil},
+		{"41012", "WVHT", func(o Observation) *float64 { return o.WaveHeight }, n(0.7)},
+		{"41012", "DPD", func(o Observation) *float64 { return o.DominantPeriod }, n(11)},
+		{"41012", "APD", func(o Observation) *float64 { return o.AveragePeriod }, n(4.2)},
+		{"41012", "MWD", func(o Observation) *float64 { return o.WaveDir }, n(143)},
+		{"41012", "PTDY", func(o Observation) *float64 { return o.Tendency }, n(0)},
+		{"41001", "DEWP", func(o Observation) *float64 { return o.DewPoint }, n(3.8)},
+		{"44024", "VIS", func(o Observation) *float64 { return o.Visibility }, n(1.6)},
+		{"ANCF1", "TIDE", func(o Observation) *float64 { return o.Tide }, n(0.93)},
+	}
+	for _, tt := range cases {
+		o, ok := stations[tt.station]
+		if !ok {
+			t.Errorf("%s not found", tt.station)
+			continue
+		}
+		got := tt.got(o)
+		switch {
+		case got == nil && tt.want == nil:
+		case got == nil || tt.want == nil:
+			t.Errorf("%s %s: got %v, want %v", tt.station, tt.field, got, tt.want)
+		case *got != *tt.want:
+			t.Errorf("%s %s: got %g, want %g", tt.station, tt.field, *got, *tt.want)
+		}
+	}
+
+	o := stations["FTPC1"]
+	if o.Lat != 37.807 || o.Lng != -122.465 {
+		t.Errorf("FTPC1 at %g, %g; want 37.807, -122.465", o.Lat, o.Lng)
+	}
+	if want := time.Date(2012, 1, 6, 8, 0, 0, 0, time.UTC); !o.Time.Equal(want) || o.Time.Location() != time.UTC {
+		t.Errorf("FTPC1 time: got %s, want %s", o.Time, want)
+	}
+}
+
+// Columns are found by name, so they may move or be added.
+func TestParseObservationsColumns(t *testing.T) {
+	data := "#STN YYYY MM DD hh mm NEW ATMP  LAT     LON\n" +
+		"#text yr mo day hr mn  xx degC  deg     deg\n" +
+		"FTPC1 2012 01 06 08 00 1.0  7.8 37.807 -122.465\n"
+	obs, err := ParseObservations(strings.NewReader(data), t.Errorf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs) != 1 || obs[0].AirTemp == nil || *obs[0].AirTemp != 7.8 || obs[0].WindSpeed != nil {
+		t.Errorf("got %+v", obs)
+	}
+
+	data = "FTPC1 2012 01 06 08 00\n"
+	if _, err := ParseObservations(strings.NewReader(data), t.Errorf); err == nil {
+		t.Errorf("no error for %q", data)
+	}
+}
+
+// A bad row is skipped, not the whole file.
+func TestParseObservationsBadRows(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/latest_obs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for _, bad := range []string{
+		"46026  37.759 -122.833 2012 01 06 07 50 320\n",
+		"46026  37.759 -122.833 2012 01 06 07 50 320 warm MM MM MM MM MM MM MM MM MM MM MM MM\n",
+		"46026  37.759 -122.833 2012 Jan 06 07 50 320 7.0 MM MM MM MM MM MM MM MM MM MM MM MM\n",
+	} {
+		withBad := append(append(append([]string{}, lines[:300]...), bad), lines[300:]...)
+		var logged []string
+		logf := func(format string, args ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		}
+		obs, err := ParseObservations(strings.NewReader(strings.Join(withBad, "")), logf)
+		if err != nil {
+			t.Errorf("%q: %s", bad, err)
+			continue
+		}
+		if len(obs) != 642 {
+			t.Errorf("%q: got %d observations, want 642", bad, len(obs))
+		}
+		if len(logged) != 1 || !strings.Contains(logged[0], "46026") {
+			t.Errorf("%q: logged %q", bad, logged)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"time"
+
+	"appengine"
+)
+
+// panels are the panels that can share the secondary box, by name.
+var panels = map[string]func(w io.Writer, c appengine.Context, d *Display){
+	"worldclocks": WorldClocks,
+	"discussion":  ForecastDiscussion,
+}
+
+// Secondary shows the display's panels in turn in one box.  They are all
+// rendered at once, and the browser rotates between them, so that the
+// page doesn't need reloading to do it.  Which panel is shown follows
+// from the time, not from when the page was loaded, so that reloading
+// the page doesn't start the rotation over.  Panels with nothing to show
+// are skipped.
+func Secondary(w io.Writer, c appengine.Context, d *Display) {
+	names := d.Panels
+	if len(names) == 0 {
+		names = []string{"worldclocks"}
+	}
+	var rendered []template.HTML
+	for _, name := range names {
+		panel, ok := panels[name]
+		if !ok {
+			c.Errorf("unknown panel %q", name)
+			continue
+		}
+		var b bytes.Buffer
+		panel(&b, c, d)
+		if b.Len() > 0 {
+			rendered = append(rendered, template.HTML(b.String()))
+		}
+	}
+	rotate := d.RotateEvery
+	if rotate == 0 {
+		rotate = 30 * time.Second
+	}
+	if err := rotation(w, rendered, time.Now(), rotate); err != nil {
+		c.Errorf("%s", err)
+	}
+}
+
+// shownPanel returns which of n panels is shown at a time, rotating every
+// period.  The browser works it out the same way.
+func shownPanel(t time.Time, period time.Duration, n int) int {
+	ms := period.Nanoseconds() / int64(time.Millisecond)
+	return int(t.UnixNano() / int64(time.Millisecond) / ms % int64(n))
+}
+
+// rotation writes the panels, showing the one due at now.
+func rotation(w io.Writer, rendered []template.HTML, now time.Time, rotate time.Duration) error {
+	type panel struct {
+		HTML   template.HTML
+		Hidden bool
+	}
+	var ps []panel
+	for i, html := range rendered {
+		ps = append(ps, panel{html, i != shownPanel(now, rotate, len(rendered))})
+	}
+	return panelTmpl.Execute(w, map[string]interface{}{
+		"Panels":   ps,
+		"Rotating": len(ps) > 1,
+		"Rotate":   rotate.Nanoseconds() / int64(time.Millisecond),
+	})
+}
+
+var panelTmpl = template.Must(template.New("panel").Parse(`
+{{range .Panels}} <div class=panel {{if .Hidden}}style="display: none"{{end}}>{{.HTML}}</div>
+{{end}}{{if .Rotating}}<script>
+ (function() {
+  var panels = document.querySelectorAll(".panel"), period = {{.Rotate}};
+  function rotate() {
+   var now = serverTime();
+   var shown = Math.floor(now / period) % panels.length;
+   for (var i = 0; i < panels.length; i++) {
+    panels[i].style.display = i == shown ? "block" : "none";
+   }
+   setTimeout(rotate, period - now % period + 10);
+  }
+  rotate();
+ })();
+</script>{{end}}
+`))
@@ -0,0 +1,83 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotation(t *testing.T) {
+	rendered := []template.HTML{"<b>clocks</b>", "<b>synopsis</b>"}
+	now := time.Unix(1348500000, 0)
+	var b bytes.Buffer
+	if err := rotation(&b, rendered, now, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	s := b.String()
+	for _, want := range []string{
+		`<div class=panel ><b>clocks</b></div>`,
+		`<div class=panel style="display: none"><b>synopsis</b></div>`,
+		`30000`,
+		`serverTime()`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%q not in %s", want, s)
+		}
+	}
+
+	// Half a minute later, as when the page is reloaded, the next
+	// panel is shown.
+	b.Reset()
+	if err := rotation(&b, rendered, now.Add(30*time.Second), 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<div class=panel style="display: none"><b>clocks</b></div>`; !strings.Contains(b.String(), want) {
+		t.Errorf("%q not in %s", want, b.String())
+	}
+
+	b.Reset()
+	if err := rotation(&b, rendered[:1], now, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "<script>") {
+		t.Errorf("one panel rotates: %s", b.String())
+	}
+}
+
+func TestShownPanel(t *testing.T) {
+	start := time.Unix(1348500000, 0)
+	for _, tt := range []struct {
+		after time.Duration
+		n     int
+		want  int
+	}{
+		{0, 2, 0},
+		{29 * time.Second, 2, 0},
+		{30 * time.Second, 2, 1},
+		{60 * time.Second, 2, 0},
+		{0, 3, 1},
+		{60 * time.Second, 3, 0},
+		{90 * time.Second, 3, 1},
+		{time.Hour, 1, 0},
+	} {
+		if got := shownPanel(start.Add(tt.after), 30*time.Second, tt.n); got != tt.want {
+			t.Errorf("shownPanel(+%s, 30s, %d) = %d, want %d", tt.after, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"appengine"
+	"appengine/memcache"
+)
+
+// Observations pass through quality control before they are stored or
+// shown, so that a glitching sensor shows nothing rather than something
+// wrong.  Measurements outside the range that is possible anywhere are
+// rejected, as are those that change faster than the weather does since
+// the station's previous report, and those that have stopped changing
+// for so long that the sensor must be stuck.  Each is checked against
+// the station's earlier accepted observations, so a rejected value is
+// never the baseline for the next check.
+
+// A limit is the quality control for one kind of measurement.
+type limit struct {
+	min, max float64
+	// rate is the greatest believable change in an hour, or zero for
+	// measurements that are naturally spiky, like wind.
+	rate float64
+	// stuck is whether a value that doesn't change is suspicious.
+	stuck bool
+}
+
+var limits = map[string]limit{
+	"WDIR": {0, 360, 0, false},
+	"WSPD": {0, 75, 0, true},
+	"GST":  {0, 100, 0, false},
+	"WVHT": {0, 30, 0, false},
+	"DPD":  {0, 40, 0, false},
+	"APD":  {0, 40, 0, false},
+	"MWD":  {0, 360, 0, false},
+	"PRES": {850, 1090, 6, true},
+	"PTDY": {-20, 20, 0, false},
+	"ATMP": {-60, 60, 6, true},
+	"WTMP": {-4, 40, 3, false},
+	"DEWP": {-70, 35, 8, true},
+	"VIS":  {0, 99, 0, false},
+	"TIDE": {-30, 30, 0, false},
+}
+
+// Changes are compared with the previous report only if it is this
+// recent.
+const spikeWindow = 3 * time.Hour
+
+// A value is stuck if it has been reported unchanged at least this many
+// times over at least this long.
+const stuckCount, stuckPeriod = 4, 6 * time.Hour
+
+// check returns the reasons any of the measurements in o should be
+// rejected, by their NDBC names, given the station's earlier accepted
+// observations, oldest first.
+func check(o Observation, history []Observation) map[string]string {
+	rejected := make(map[string]string)
+	for name, m := range measurements(&o) {
+		if *m == nil {
+			continue
+		}
+		v := **m
+		l := limits[name]
+		if v < l.min || v > l.max {
+			rejected[name] = fmt.Sprintf("%g outside [%g, %g]", v, l.min, l.max)
+			continue
+		}
+
+		// Earlier values of this measurement, newest first.
+		var times []time.Time
+		var values []float64
+		for i := len(history) - 1; i >= 0; i-- {
+			h := history[i]
+			if p := *measurements(&h)[name]; p != nil && h.Time.Before(o.Time) {
+				times = append(times, h.Time)
+				values = append(values, *p)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		if dt := o.Time.Sub(times[0]); l.rate > 0 && dt <= spikeWindow {
+			// Allow at least an hour's change, since reports
+			// are often only a few minutes apart.
+			allowed := l.rate * math.Max(dt.Hours(), 1)
+			if math.Abs(v-values[0]) > allowed {
+				rejected[name] = fmt.Sprintf("%g is a spike from %g", v, values[0])
+				continue
+			}
+		}
+
+		// Calm is often steady for hours.
+		if l.stuck && v != 0 {
+			n := 0
+			for n < len(values) && values[n] == v {
+				n++
+			}
+			if n > 0 && n+1 >= stuckCount && o.Time.Sub(times[n-1]) >= stuckPeriod {
+				rejected[name] = fmt.Sprintf("stuck at %g", v)
+			}
+		}
+	}
+	return rejected
+}
+
+// reject removes measurements, by their NDBC names, from an observation.
+func (o *Observation) reject(names []string) {
+	m := measurements(o)
+	for _, name := range names {
+		if p, ok := m[name]; ok {
+			*p = nil
+		}
+	}
+}
+
+// cacheRejections keeps the measurements rejected from the observations
+// in the latest data, so that they aren't shown.  It doesn't matter if
+// they outlive the data, since they are keyed by observation time.
+func cacheRejections(c appengine.Context, rejected map[string][]string) error {
+	b, err := json.Marshal(rejected)
+	if err != nil {
+		return err
+	}
+	return memcache.Set(c, &memcache.Item{Key: "conditions_rejected", Value: b})
+}
+
+// screen removes the measurements that are out of range, or were
+// rejected when the data was fetched, from observations to be shown.
+func screen(c appengine.Context, obs []Observation) []Observation {
+	rejected := make(map[string][]string)
+	item, err := memcache.Get(c, "conditions_rejected")
+	if err == nil {
+		err = json.Unmarshal(item.Value, &rejected)
+	}
+	if err != nil && err != memcache.ErrCacheMiss {
+		c.Errorf("%s", err)
+	}
+	screened := make([]Observation, len(obs))
+	for i, o := range obs {
+		var names []string
+		for name := range check(o, nil) {
+			names = append(names, name)
+		}
+		o.reject(append(names, rejected[observationKey(o)]...))
+		screened[i] = o
+	}
+	return screened
+}
@@ -0,0 +1,75 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2012, 3, 1, 12, 0, 0, 0, time.UTC)
+	v := func(v float64) *float64 { return &v }
+	at := func(ago time.Duration, temp, speed *float64) Observation {
+		return Observation{Station: "FTPC1", Time: now.Add(-ago), AirTemp: temp, WindSpeed: speed}
+	}
+	steady := []Observation{
+		at(7*time.Hour, v(14), v(0)),
+		at(5*time.Hour, v(14), v(0)),
+		at(3*time.Hour, v(14), v(0)),
+		at(1*time.Hour, v(14), v(0)),
+	}
+	cases := []struct {
+		name    string
+		o       Observation
+		history []Observation
+		want    []string
+	}{
+		{"good", at(0, v(14.5), v(3)), steady[2:], nil},
+		{"out of range", at(0, v(75), v(-1)), nil, []string{"ATMP", "WSPD"}},
+		{"spike", at(0, v(40), v(3)), steady[2:], []string{"ATMP"}},
+		{"change over hours", at(0, v(24), v(3)), steady[2:3], nil},
+		{"change since an old report", at(0, v(40), v(3)),
+			[]Observation{at(4*time.Hour, v(14), nil)}, nil},
+		{"previous report missing", at(0, v(40), v(3)),
+			append(steady[2:], at(10*time.Minute, nil, v(3))), []string{"ATMP"}},
+		{"stuck", at(0, v(14), v(3)), steady, []string{"ATMP"}},
+		{"steady but not for long", at(0, v(14), v(3)), steady[1:], nil},
+		{"calm", at(0, v(15), v(0)), steady, nil},
+		{"later observation ignored", at(2*time.Hour, v(14), v(3)),
+			[]Observation{at(time.Hour, v(40), nil)}, nil},
+	}
+	for _, tt := range cases {
+		got := check(tt.o, tt.history)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: rejected %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for _, name := range tt.want {
+			if _, ok := got[name]; !ok {
+				t.Errorf("%s: rejected %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestReject(t *testing.T) {
+	temp, speed := 40.0, 3.0
+	o := Observation{AirTemp: &temp, WindSpeed: &speed}
+	o.reject([]string{"ATMP", "XXXX"})
+	if o.AirTemp != nil || o.WindSpeed == nil {
+		t.Errorf("reject(ATMP) left AirTemp %v, WindSpeed %v", o.AirTemp, o.WindSpeed)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"math"
+	"sort"
+)
+
+// Stations further away than this, in km, are too far away to say
+// much about conditions here.
+const maxStationDistance = 50
+
+// stationOrder returns observations in the order their stations should be
+// used: the preferred stations in order, then the others by distance
+// from lat, lng, up to maxStationDistance.
+func stationOrder(obs []Observation, preferred []string, lat, lng float64) []Observation {
+	rank := make(map[string]int)
+	for i, s := range preferred {
+		rank[s] = i + 1
+	}
+	var first, rest []Observation
+	for _, o := range obs {
+		if rank[o.Station] > 0 {
+			first = append(first, o)
+		} else if distance(lat, lng, o.Lat, o.Lng) <= maxStationDistance {
+			rest = append(rest, o)
+		}
+	}
+	sort.Sort(byRank{first, rank})
+	sort.Sort(byDistance{rest, lat, lng})
+	return append(first, rest...)
+}
+
+// pick returns the first observation in obs that reports the measurement
+// returned by f.
+func pick(obs []Observation, f func(Observation) *float64) (*float64, Observation) {
+	for _, o := range obs {
+		if v := f(o); v != nil {
+			return v, o
+		}
+	}
+	return nil, Observation{}
+}
+
+// distance returns the great-circle distance in km between two points.
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const r = 6371 // mean radius of the earth in km
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dlat := rad(lat2 - lat1)
+	dlng := rad(lng2 - lng1)
+	a := math.Pow(math.Sin(dlat/2), 2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Pow(math.Sin(dlng/2), 2)
+	return 2 * r * math.Asin(math.Sqrt(a))
+}
+
+type byRank struct {
+	obs  []Observation
+	rank map[string]int
+}
+
+func (s byRank) Len() int      { return len(s.obs) }
+func (s byRank) Swap(i, j int) { s.obs[i], s.obs[j] = s.obs[j], s.obs[i] }
+func (s byRank) Less(i, j int) bool {
+	return s.rank[s.obs[i].Station] < s.rank[s.obs[j].Station]
+}
+
+type byDistance struct {
+	obs      []Observation
+	lat, lng float64
+}
+
+func (s byDistance) Len() int      { return len(s.obs) }
+func (s byDistance) Swap(i, j int) { s.obs[i], s.obs[j] = s.obs[j], s.obs[i] }
+func (s byDistance) Less(i, j int) bool {
+	return distance(s.lat, s.lng, s.obs[i].Lat, s.obs[i].Lng) <
+		distance(s.lat, s.lng, s.obs[j].Lat, s.obs[j].Lng)
+}
@@ -0,0 +1,69 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStationOrder(t *testing.T) {
+	f, err := os.Open("testdata/latest_obs.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	obs, err := ParseObservations(f, t.Errorf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs = stationOrder(obs, []string{"OMHC1", "FTPC1"}, Lat, Lng)
+	if obs[0].Station != "OMHC1" || obs[1].Station != "FTPC1" {
+		t.Errorf("preferred stations not first: %s, %s", obs[0].Station, obs[1].Station)
+	}
+	for _, o := range obs {
+		if d := distance(Lat, Lng, o.Lat, o.Lng); d > maxStationDistance {
+			t.Errorf("%s is %.0f km away", o.Station, d)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		f       func(o Observation) *float64
+		station string
+		want    float64
+	}{
+		{"wind", func(o Observation) *float64 { return o.WindSpeed }, "OMHC1", 3.1},
+		// OMHC1 has no temperature, so the next preferred station.
+		{"temp", func(o Observation) *float64 { return o.AirTemp }, "FTPC1", 7.8},
+		// Neither has a dew point, so the nearest that does.
+		{"dew point", func(o Observation) *float64 { return o.DewPoint }, "TIBC1", 7.1},
+		// Nothing nearby has a visibility.
+		{"visibility", func(o Observation) *float64 { return o.Visibility }, "", 0},
+	}
+	for _, tt := range cases {
+		v, o := pick(obs, tt.f)
+		if o.Station != tt.station || (v == nil) != (tt.station == "") || (v != nil && *v != tt.want) {
+			t.Errorf("%s: got %v from %q, want %g from %q", tt.name, v, o.Station, tt.want, tt.station)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// San Francisco to Los Angeles.
+	if d := distance(37.79, -122.42, 34.05, -118.24); d < 555 || d > 565 {
+		t.Errorf("got %.0f km, want about 560", d)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"appengine"
+
+	"solar"
+)
+
+// Size of the sun arc, in pixels.
+const arcWidth, arcHeight = 64, 40
+
+// SunArc draws the path of the sun across the sky today, from sunrise to
+// sunset, with a marker at its current position.  Its height is the
+// sun's elevation, scaled so that noon is at the top.  In polar night
+// only the horizon is drawn.
+func SunArc(w io.Writer, c appengine.Context) {
+	sunArc(w, time.Now(), Lat, Lng)
+}
+
+func sunArc(w io.Writer, now time.Time, lat, lng float64) {
+	// Find the solar day we are in, and its sunrise and sunset.
+	events := solar.Events(now.Add(-24*time.Hour), now.Add(24*time.Hour), lat, lng)
+	var noon time.Time
+	for _, e := range events {
+		if e.Kind == solar.Noon && math.Abs(e.Time.Sub(now).Hours()) <= 12 {
+			noon = e.Time
+		}
+	}
+	if noon.IsZero() {
+		return
+	}
+	rise, set := noon.Add(-12*time.Hour), noon.Add(12*time.Hour)
+	for _, e := range events {
+		if e.Condition != solar.Occurred || e.Time.Before(rise) || e.Time.After(set) {
+			continue
+		}
+		if e.Kind == solar.Sunrise && e.Time.Before(noon) {
+			rise = e.Time
+		} else if e.Kind == solar.Sunset && e.Time.After(noon) {
+			set = e.Time
+		}
+	}
+	_, top := solar.Position(noon, lat, lng)
+
+	x := func(t time.Time) float64 {
+		return arcWidth * t.Sub(rise).Seconds() / set.Sub(rise).Seconds()
+	}
+	y := func(t time.Time) float64 {
+		_, el := solar.Position(t, lat, lng)
+		if el < 0 {
+			el = 0
+		}
+		return arcHeight - 4 - (arcHeight-8)*el/top
+	}
+
+	fmt.Fprintf(w, `<svg width=%d height=%d viewBox="0 0 %d %d">`,
+		arcWidth, arcHeight, arcWidth, arcHeight)
+	fmt.Fprintf(w, `<line x1=0 y1=%d x2=%d y2=%d stroke="#888" />`,
+		arcHeight-4, arcWidth, arcHeight-4)
+	if top <= 0 {
+		// Polar night: the sun doesn't rise today.
+		io.WriteString(w, `</svg>`)
+		return
+	}
+	io.WriteString(w, `<polyline fill=none stroke=black points="`)
+	const steps = 32
+	for i := 0; i <= steps; i++ {
+		t := rise.Add(set.Sub(rise) * time.Duration(i) / steps)
+		fmt.Fprintf(w, "%.1f,%.1f ", x(t), y(t))
+	}
+	io.WriteString(w, `" />`)
+	if !now.Before(rise) && now.Before(set) {
+		fmt.Fprintf(w, `<circle cx=%.1f cy=%.1f r=4 />`, x(now), y(now))
+	}
+	io.WriteString(w, `</svg>`)
+}
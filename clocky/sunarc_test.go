@@ -0,0 +1,73 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"solar"
+)
+
+func TestSunArc(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	cases := []struct {
+		name     string
+		now      time.Time
+		lat, lng float64
+		arc, sun bool
+	}{
+		{"day", time.Date(2012, 12, 21, 12, 0, 0, 0, pst), 37.79, -122.42, true, true},
+		{"night", time.Date(2012, 12, 21, 22, 0, 0, 0, pst), 37.79, -122.42, true, false},
+		{"before sunrise", time.Date(2012, 12, 21, 6, 0, 0, 0, pst), 37.79, -122.42, true, false},
+		// Tromsø, in polar night and then under the midnight sun.
+		{"polar night", time.Date(2012, 12, 21, 11, 0, 0, 0, time.UTC), 69.65, 18.96, false, false},
+		{"midnight sun", time.Date(2012, 6, 21, 23, 0, 0, 0, time.UTC), 69.65, 18.96, true, true},
+	}
+	for _, tt := range cases {
+		var b bytes.Buffer
+		sunArc(&b, tt.now, tt.lat, tt.lng)
+		s := b.String()
+		if !strings.HasPrefix(s, "<svg") || !strings.HasSuffix(s, "</svg>") || !strings.Contains(s, "<line") {
+			t.Errorf("%s: no horizon in %s", tt.name, s)
+		}
+		if arc := strings.Contains(s, "<polyline"); arc != tt.arc {
+			t.Errorf("%s: arc drawn is %v, want %v", tt.name, arc, tt.arc)
+		}
+		if sun := strings.Contains(s, "<circle"); sun != tt.sun {
+			t.Errorf("%s: sun drawn is %v, want %v", tt.name, sun, tt.sun)
+		}
+	}
+}
+
+// The arc starts and ends on the horizon, and the sun at noon is at the
+// top.
+func TestSunArcGeometry(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	noon := solar.SolarNoon(time.Date(2012, 12, 21, 12, 0, 0, 0, pst), -122.42)
+	var b bytes.Buffer
+	sunArc(&b, noon, 37.79, -122.42)
+	s := b.String()
+	start := strings.Index(s, `points="`) + len(`points="`)
+	points := strings.Fields(s[start : start+strings.Index(s[start:], `"`)])
+	if first, last := points[0], points[len(points)-1]; first != "0.0,36.0" || last != "64.0,36.0" {
+		t.Errorf("arc runs from %s to %s, want 0.0,36.0 to 64.0,36.0", first, last)
+	}
+	if !strings.Contains(s, `<circle cx=32.0 cy=4.0 r=4 />`) {
+		t.Errorf("sun not at the top of the arc at noon: %s", s)
+	}
+}
@@ -15,40 +15,238 @@
 package clocky
 
 import (
+	"fmt"
 	"html/template"
 	"io"
+	"net/http"
+	"strings"
 	"time"
 
 	"appengine"
 
+	"lunar"
 	"solar"
 )
 
-const TimeFormat = "3:04\u2009pm"
+// FirstLight and LastLight are the twilight events shown.  Civil
+// twilight is about when it becomes too dark to see outdoors without
+// lights.
+const FirstLight, LastLight = solar.CivilDawn, solar.CivilDusk
 
-const Zone = "America/Los_Angeles"
+var sunLabels = map[solar.Kind]string{
+	solar.Sunrise: "sunrise",
+	solar.Sunset:  "sunset",
+	FirstLight:    "first light",
+	LastLight:     "last light",
+}
 
-func Time(w io.Writer, c appengine.Context) {
-	location, _ := time.LoadLocation(Zone)
-	now := time.Now().In(location)
-	sunrise := solar.Rise(now, Lat, Lng).In(location)
-	sunset := solar.Set(now, Lat, Lng).In(location)
-	sun1 := "sunrise " + sunrise.Format(TimeFormat)
-	sun2 := "sunset " + sunset.Format(TimeFormat)
-	if sunrise.Sub(sunset) > 0 {
-		sun1, sun2 = sun2, sun1
+func Time(w io.Writer, c appengine.Context, d *Display) {
+	location, err := time.LoadLocation(d.Zone)
+	if err != nil {
+		// Falling back to UTC would show a plausible but wrong
+		// time, which is worse than none.
+		c.Errorf("%s", err)
+		io.WriteString(w, `<div class=header>No time zone</div><div class=smaller>`)
+		template.HTMLEscape(w, []byte(err.Error()))
+		io.WriteString(w, `</div>`)
+		return
 	}
-	timeTmpl.Execute(w, map[string]string{
-		"Big":   now.Format("3:04"),
-		"Small": now.Format(":05\u2009pm"),
-		"Date":  now.Format("Monday, January 2"),
-		"Sun1":  sun1,
-		"Sun2":  sun2,
+	now := time.Now().In(location)
+	layout := d.TimeFormat()
+	big, small := d.clockFormats()
+	events := solar.Events(now, now.Add(48*time.Hour), Lat, Lng)
+	_, offset := now.Zone()
+	timeTmpl.Execute(w, map[string]interface{}{
+		"Now":    now.UnixNano() / int64(time.Millisecond),
+		"Offset": offset,
+		"Hour":   now.Hour(),
+		"Hour24": d.Hour24,
+		"Drift":  d.MaxDrift.Nanoseconds() / int64(time.Millisecond),
+		"Big":    now.Format(big),
+		"Small":  now.Format(small),
+		"Date":   now.Format(d.DateFormat),
+		"Sun":    nextTwo(events, layout, solar.Sunrise, solar.Sunset),
+		"Light":  nextTwo(events, layout, FirstLight, LastLight),
+		"Moon":   moon(now, layout),
+		"Day":    dayLength(now),
+		"Noon":   "solar noon " + solar.SolarNoon(now, Lng).Format(layout),
+		"Season": nextSeason(now),
 	})
 }
 
+// dayLength describes the length of today and its change from yesterday,
+// such as "10h 12m, +1m 48s".
+func dayLength(now time.Time) string {
+	today := solar.DayLength(now, Lat, Lng)
+	change := today - solar.DayLength(now.AddDate(0, 0, -1), Lat, Lng)
+	sign := "+"
+	if change < 0 {
+		sign, change = "\u2212", -change
+	}
+	return fmt.Sprintf("daylight %dh\u2009%02dm, %s%dm\u2009%02ds",
+		int(today.Hours()), int(today.Minutes())%60,
+		sign, int(change.Minutes()), int(change.Seconds())%60)
+}
+
+// Names of the equinoxes and solstices, in the order of solar.Seasons,
+// in the northern and southern hemispheres.
+var seasonNames = [2][4]string{
+	{"spring equinox", "summer solstice", "autumn equinox", "winter solstice"},
+	{"autumn equinox", "winter solstice", "spring equinox", "summer solstice"},
+}
+
+// nextSeason gives the name and date of the next equinox or solstice.
+func nextSeason(now time.Time) string {
+	hemisphere := 0
+	if Lat < 0 {
+		hemisphere = 1
+	}
+	for _, year := range []int{now.Year(), now.Year() + 1} {
+		for i, t := range solar.Seasons(year) {
+			if t.After(now) {
+				return seasonNames[hemisphere][i] + " " +
+					t.In(now.Location()).Format("Jan 2")
+			}
+		}
+	}
+	return ""
+}
+
+// moon describes the phase of the moon and the next moonrise and moonset.
+func moon(now time.Time, layout string) string {
+	s := []string{fmt.Sprintf("%s %.0f%%",
+		lunar.PhaseName(now), 100*lunar.Illumination(now))}
+	labels := []string{"moonrise", "moonset"}
+	times := []time.Time{lunar.Rise(now, Lat, Lng), lunar.Set(now, Lat, Lng)}
+	if times[1].Before(times[0]) {
+		labels[0], labels[1] = labels[1], labels[0]
+		times[0], times[1] = times[1], times[0]
+	}
+	for i, t := range times {
+		if !t.IsZero() {
+			s = append(s, labels[i]+" "+t.Format(layout))
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// nextTwo describes the next two events of the given kinds, formatting
+// times with layout.
+func nextTwo(events []solar.Event, layout string, kind1, kind2 solar.Kind) string {
+	var s []string
+	for _, e := range events {
+		if e.Kind != kind1 && e.Kind != kind2 {
+			continue
+		}
+		if e.Condition != solar.Occurred {
+			// Near the poles, say so rather than looking
+			// ahead to an event that may be weeks away.
+			s = append(s, e.String())
+			break
+		}
+		s = append(s, sunLabels[e.Kind]+" "+e.Time.Format(layout))
+		if len(s) == 2 {
+			break
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// nowHandler serves the server's time, in milliseconds since the Unix
+// epoch, for browsers to correct their clocks.
+func nowHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "%d", time.Now().UnixNano()/int64(time.Millisecond))
+}
+
+func init() {
+	http.HandleFunc("/now", nowHandler)
+}
+
+// The clock is ticked by the browser, from the server's time when the page
+// was rendered.  This avoids reloading the page, which makes an e-ink
+// display flash, just to show the seconds.  The page is reloaded when the
+// hour changes, to update everything else, including the date and the
+// offset from UTC.  Each tick is timed to just after the start of the
+// next second, so that the ticks don't drift.
+//
+// Cheap devices' clocks drift too, so the browser also asks the server
+// for the time every minute.  Like NTP, it assumes the server answered
+// halfway through the round trip, and prefers the answers that came back
+// quickest, allowing a second a minute for the device's clock to have
+// drifted or been set since.  If it is too far off, a warning is shown.
+// The correction is kept in skew, in the page's header, for everything
+// else the browser times.
 var timeTmpl = template.Must(template.New("time").Parse(`
- <div class=header><span class=larger>{{.Big}}</span>{{.Small}}</div>
+ <div class=header><span class=larger id=big>{{.Big}}</span><span id=small>{{.Small}}</span></div>
  <div class=smaller>{{.Date}}</div>
- <div class=smaller>{{.Sun1}}, {{.Sun2}}</div>
+ <div class="smaller drift" id=drift style="display: none"></div>
+ <div class=smaller>{{.Sun}}</div>
+ <div class=smaller>{{.Light}}</div>
+ <div class=smaller>{{.Moon}}</div>
+ <div class=smaller>{{.Day}}</div>
+ <div class=smaller>{{.Noon}}, {{.Season}}</div>
+ <script>
+  (function() {
+   skew = {{.Now}} - new Date().getTime();
+   var offset = {{.Offset}} * 1000, hour = {{.Hour}}, hour24 = {{.Hour24}};
+   function pad(n) { return n < 10 ? "0" + n : "" + n; }
+   function tick() {
+    var now = serverTime();
+    var t = new Date(now + offset);
+    var h = t.getUTCHours(), m = t.getUTCMinutes(), s = t.getUTCSeconds();
+    if (h != hour) {
+     location.reload();
+     return;
+    }
+    var big = (hour24 ? pad(h) : (h % 12 || 12)) + ":" + pad(m);
+    var small = ":" + pad(s) + (hour24 ? "" : (h < 12 ? "\u2009am" : "\u2009pm"));
+    document.getElementById("big").innerHTML = big;
+    document.getElementById("small").innerHTML = small;
+    setTimeout(tick, 1010 - now % 1000);
+   }
+   function warn() {
+    var drift = Math.round(-skew / 1000);
+    var e = document.getElementById("drift");
+    if ({{.Drift}} == 0 || Math.abs(drift) * 1000 <= {{.Drift}}) {
+     e.style.display = "none";
+     return;
+    }
+    var fast = drift > 0 ? "fast" : "slow";
+    drift = Math.abs(drift);
+    var text = drift % 60 + "\u2009s";
+    if (drift >= 60) {
+     text = Math.floor(drift / 60) + "\u2009min " + text;
+    }
+    e.innerHTML = "device clock " + text + " " + fast;
+    e.style.display = "block";
+   }
+   var best = null;
+   function sync() {
+    var req = new XMLHttpRequest();
+    var sent = new Date().getTime();
+    req.onreadystatechange = function() {
+     if (req.readyState != 4) {
+      return;
+     }
+     var received = new Date().getTime();
+     var server = parseInt(req.responseText, 10);
+     if (req.status == 200 && !isNaN(server)) {
+      var delay = received - sent;
+      if (best === null || delay <= best) {
+       best = delay;
+       skew = server + delay / 2 - received;
+       warn();
+      }
+      best += 1000;
+     }
+     setTimeout(sync, 60000);
+    };
+    req.open("GET", "/now?" + sent, true);
+    req.send(null);
+   }
+   tick();
+   sync();
+  })();
+ </script>
 `))
@@ -0,0 +1,100 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"units"
+)
+
+// Quantities are formatted as HTML, with a thin space before the unit and
+// no line break within.
+
+// temperature formats a temperature in degrees, without the scale, which
+// is assumed to be known to the reader.
+func (d *Display) temperature(t units.Temperature) string {
+	return fmt.Sprintf("%.1f°", t.In(d.Units.Temperature))
+}
+
+// speed formats a speed to the nearest whole unit.
+func (d *Display) speed(s units.Speed) string {
+	u := d.Units.Speed
+	return quantity(fmt.Sprintf("%.0f", s.In(u)), u.String())
+}
+
+func (d *Display) pressure(p units.Pressure) string {
+	u := d.Units.Pressure
+	format := "%.0f"
+	if u == units.InchesOfMercury {
+		format = "%.2f"
+	}
+	return quantity(fmt.Sprintf(format, p.In(u)), u.String())
+}
+
+func (d *Display) distance(x units.Distance) string {
+	u := d.Units.Distance
+	return quantity(fmt.Sprintf("%.1f", x.In(u)), u.String())
+}
+
+func quantity(n, unit string) string {
+	// A word joiner keeps units such as "km/h" from breaking at the
+	// slash.
+	unit = strings.Replace(unit, "/", "/\u2060", -1)
+	return `<span style="white-space: nowrap">` + n + `&thinsp;` + unit + `</span>`
+}
+
+var (
+	// Forecast temperatures follow one of these phrases, and have no
+	// unit.  A unit means it's some other quantity, such as "gusts as
+	// high as 40 km/h".
+	forecastTempRegexp = regexp.MustCompile(
+		`((?:high|low) (?:near|around|of)|(?:rising|falling) to (?:near|around)|steady (?:near|around)|as (?:low|high) as) (-?[0-9]+)( km/h)?`)
+	forecastSpeedRegexp = regexp.MustCompile(`([0-9]+)( (?:and|to) )?([0-9]+)? km/h`)
+)
+
+// convertForecast converts the temperatures and speeds in a metric NWS
+// forecast text to the given units.
+func convertForecast(text string, s units.System) string {
+	if s.Temperature != units.Celsius {
+		text = forecastTempRegexp.ReplaceAllStringFunc(text, func(m string) string {
+			sm := forecastTempRegexp.FindStringSubmatch(m)
+			if sm[3] != "" {
+				return m
+			}
+			n, _ := strconv.Atoi(sm[2])
+			t := units.Celsius.Of(float64(n)).In(s.Temperature)
+			return fmt.Sprintf("%s %.0f", sm[1], t)
+		})
+	}
+	if s.Speed != units.KilometresPerHour {
+		convert := func(n string) string {
+			v, _ := strconv.Atoi(n)
+			return fmt.Sprintf("%.0f", units.KilometresPerHour.Of(float64(v)).In(s.Speed))
+		}
+		text = forecastSpeedRegexp.ReplaceAllStringFunc(text, func(m string) string {
+			sm := forecastSpeedRegexp.FindStringSubmatch(m)
+			r := convert(sm[1])
+			if sm[3] != "" {
+				r += sm[2] + convert(sm[3])
+			}
+			return r + " " + s.Speed.String()
+		})
+	}
+	return text
+}
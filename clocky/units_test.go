@@ -0,0 +1,55 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"testing"
+
+	"units"
+)
+
+func TestConvertForecast(t *testing.T) {
+	text := "Sunny, with a high near 20. West wind 13 to 19 km/h, with gusts as high as 32 km/h. " +
+		"Temperature falling to around 10 by 5pm."
+	cases := []struct {
+		system units.System
+		want   string
+	}{
+		{units.Metric, text},
+		{units.Imperial, "Sunny, with a high near 68. West wind 8 to 12 mph, with gusts as high as 20 mph. " +
+			"Temperature falling to around 50 by 5pm."},
+		{units.Marine, "Sunny, with a high near 20. West wind 7 to 10 kn, with gusts as high as 17 kn. " +
+			"Temperature falling to around 10 by 5pm."},
+	}
+	for _, tt := range cases {
+		if got := convertForecast(text, tt.system); got != tt.want {
+			t.Errorf("convertForecast(%+v):\n got %q\nwant %q", tt.system, got, tt.want)
+		}
+	}
+}
+
+func TestQuantities(t *testing.T) {
+	d := &Display{Units: units.Imperial}
+	if got, want := d.temperature(20), "68.0°"; got != want {
+		t.Errorf("temperature(20) = %q, want %q", got, want)
+	}
+	if got, want := d.pressure(1013.25), `<span style="white-space: nowrap">29.92&thinsp;inHg</span>`; got != want {
+		t.Errorf("pressure(1013.25) = %q, want %q", got, want)
+	}
+	d = &Display{Units: units.Marine}
+	if got, want := d.speed(units.Knots.Of(12)), `<span style="white-space: nowrap">12&thinsp;kn</span>`; got != want {
+		t.Errorf("speed(12 kn) = %q, want %q", got, want)
+	}
+}
@@ -15,214 +15,248 @@
 package clocky
 
 import (
-	"encoding/xml"
+	"bytes"
 	"fmt"
 	"io"
 	"math"
-	"regexp"
-	"strconv"
 	"strings"
 	"text/template" // TODO: Switch to Go 1's html/template.
+	"time"
 
 	"appengine"
 	"appengine/memcache"
-)
 
-// WindChill returns the Celsius wind chill (2001 North American
-// formula) for a given air temperature in degrees Celsius and a wind
-// speed in km/h.
-func WindChill(temp, speed float64) *float64 {
-	if temp > 10 || speed <= 4.8 {
-		return nil
-	}
-	chill := 13.12 + 0.6215*temp - 11.37*math.Pow(speed, 0.16) + 0.3965*temp*math.Pow(speed, 0.16)
-	return &chill
-}
+	"meteo"
+	"units"
+)
 
-func Cardinal(degrees int) string {
-	switch {
-	case degrees < 0:
-		break
-	case degrees < 23:
-		return "N"
-	case degrees < 68:
-		return "NW"
-	case degrees < 113:
-		return "W"
-	case degrees < 158:
-		return "SW"
-	case degrees < 203:
-		return "S"
-	case degrees < 248:
-		return "SE"
-	case degrees < 293:
-		return "E"
-	case degrees < 338:
-		return "NE"
-	case degrees < 360:
-		return "N"
+func Conditions(w io.Writer, c appengine.Context, d *Display) {
+	item, err := memcache.Get(c, "conditions")
+	if err != nil {
+		c.Errorf("%s", err)
+		return
 	}
-	return ""
-}
 
-func Conditions(w io.Writer, c appengine.Context) {
-	item, err := memcache.Get(c, "conditions")
+	// Bad rows were logged as warnings when the data was recorded.
+	obs, err := ParseObservations(bytes.NewReader(item.Value), c.Debugf)
 	if err != nil {
 		c.Errorf("%s", err)
 		return
 	}
+	now := time.Now()
+	obs = screen(c, obs)
+	obs = stationOrder(recent(obs, now, d.HideAfter), d.Stations, Lat, Lng)
+	stale := func(o Observation) string {
+		if d.StaleAfter > 0 && now.Sub(o.Time) > d.StaleAfter {
+			return "stale"
+		}
+		return "fresh"
+	}
+
+	show := make(map[string]bool)
+	for _, f := range d.Fields {
+		show[f] = true
+	}
 
 	var dir string
-	var speed, temp, chill *float64
+	var speed *float64
 
-	for _, line := range strings.Split(string(item.Value), "\n") {
-		if len(line) != 116 || line[0] == '#' {
-			continue
-		}
-		// FTPC1 is a C-MAN automated buoy near Crissy Field.
-		if line[:5] != "FTPC1" {
-			continue
-		}
-		if n, err := strconv.Atoi(strings.TrimSpace(line[40:43])); err != nil || n < 0 || n > 359 {
-			c.Errorf("weather: bad wind direction in %q", line)
-		} else {
-			dir = Cardinal(n)
-		}
-		if n, err := strconv.ParseFloat(strings.TrimSpace(line[44:49]), 64); err != nil {
-			c.Errorf("weather: bad wind speed in %q", line)
-		} else {
-			n *= 3.6 // m/s to km/h
-			speed = &n
+	temp, tempObs := pick(obs, func(o Observation) *float64 { return o.AirTemp })
+	// Direction is only meaningful with the speed from the same station.
+	mps, windObs := pick(obs, func(o Observation) *float64 { return o.WindSpeed })
+	if mps != nil {
+		// meteo wants km/h.
+		n := units.Speed(*mps).In(units.KilometresPerHour)
+		speed = &n
+		if windObs.WindDir != nil {
+			dir = Cardinal(int(*windObs.WindDir))
 		}
-		if n, err := strconv.ParseFloat(strings.TrimSpace(line[87:92]), 64); err != nil {
-			c.Errorf("weather: bad temp in %q", line)
-		} else {
-			temp = &n
-		}
-		break
-	}
-	if temp != nil && speed != nil {
-		chill = WindChill(*temp, *speed)
 	}
+	dew, dewObs := pick(obs, func(o Observation) *float64 { return o.DewPoint })
 
-	io.WriteString(w, `<div class=header>`)
-	if temp != nil {
-		// Don't round this, since we are using the value
-		// directly from the data, not a converted value like
-		// wind speed or a derived value like wind chill.
-		fmt.Fprintf(w, `<span class=larger>%.1f°</span> `, *temp)
-	}
+	var wind bytes.Buffer
 	switch {
-	case speed == nil:
+	case speed == nil || !show["wind"]:
 		// Output nothing.
-	case chill != nil && *chill < *temp-1:
-		fmt.Fprintf(w, `wind chill %.1f°`, *chill+0.05)
 	case *speed > 1:
-		fmt.Fprintf(w,
-			" %s wind <span style=\"white-space: nowrap\">%d&thinsp;km/\u2060h</span>",
-			dir, int(*speed+0.5))
+		var gust *units.Speed
+		if windObs.Gust != nil {
+			g := units.Speed(*windObs.Gust)
+			gust = &g
+		}
+		fmt.Fprintf(&wind, "<span class=%s>", stale(windObs))
+		windDial(&wind, d, windObs.WindDir, units.Speed(*mps), gust)
+		fmt.Fprintf(&wind, " %s wind</span>", dir)
+		station(&wind, windObs, now)
 	default:
-		io.WriteString(w, `wind calm`)
+		fmt.Fprintf(&wind, `<span class=%s>wind calm</span>`, stale(windObs))
+		station(&wind, windObs, now)
 	}
-	io.WriteString(w, `</div>`)
-}
-
-var (
-	nbspRegexp   = regexp.MustCompile(` [0-9]+\.`)
-	thinspRegexp = regexp.MustCompile(`[0-9] (am|pm|km/h)`)
-)
 
-func Forecast(w io.Writer, c appengine.Context) {
-	item, err := memcache.Get(c, "forecast")
-	if err != nil {
-		c.Errorf("%s", err)
-		return
+	io.WriteString(w, `<div class=header>`)
+	if temp != nil && show["temp"] {
+		// Don't round this, since in Celsius we are using the
+		// value directly from the data, not a converted value
+		// like wind speed or a derived value like wind chill.
+		fmt.Fprintf(w, `<span class="larger %s">%s</span>`,
+			stale(tempObs), d.temperature(units.Temperature(*temp)))
+		station(w, tempObs, now)
+		io.WriteString(w, ` `)
 	}
-
-	data := struct {
-		Data []struct {
-			Type       string `xml:"type,attr"`
-			TimeLayout []struct {
-				LayoutKey      string `xml:"layout-key"`
-				StartValidTime []struct {
-					PeriodName string `xml:"period-name,attr"`
-				} `xml:"start-valid-time"`
-			} `xml:"time-layout"`
-			Parameters struct {
-				WordedForecast struct {
-					TimeLayout string   `xml:"time-layout,attr"`
-					Text       []string `xml:"text"`
-				} `xml:"wordedForecast"`
-			} `xml:"parameters"`
-		} `xml:"data"`
-	}{}
-	p := xml.NewDecoder(strings.NewReader(string(item.Value)))
-	// NWS serves XML in ISO-8859-1 for no reason; the data is really ASCII.
-	p.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
-		return input, nil
-	}
-	if err = p.DecodeElement(&data, nil); err != nil {
-		c.Errorf("%s", err)
-		return
+	// If it feels much warmer or colder than it is, say so instead of
+	// the wind, which moves down a line.
+	var parts []string
+	feels, name := 0.0, ""
+	if temp != nil {
+		feels, name = meteo.FeelsLike(*temp, dew, speed)
 	}
-
-	io.WriteString(w, `<div class=smaller style="text-align: left">`)
-	for _, d := range data.Data {
-		if d.Type != "forecast" {
-			continue
+	if name != "" && name != "temperature" && math.Abs(feels-*temp) > 1 {
+		fmt.Fprintf(w, `<span class=%s>%s %s</span>`,
+			stale(tempObs), name, d.temperature(units.Temperature(feels)))
+		if wind.Len() > 0 {
+			parts = append(parts, wind.String())
 		}
-		var periods []string
-		for _, tl := range d.TimeLayout {
-			if tl.LayoutKey != d.Parameters.WordedForecast.TimeLayout {
+	} else {
+		w.Write(wind.Bytes())
+	}
+	io.WriteString(w, `</div>`)
+
+	// Lesser measurements go on one line, in the order configured.
+	for _, f := range d.Fields {
+		var b bytes.Buffer
+		switch f {
+		case "gust":
+			gust, o := pick(obs, func(o Observation) *float64 { return o.Gust })
+			if gust == nil {
 				continue
 			}
-			for _, svt := range tl.StartValidTime {
-				pn := svt.PeriodName
-				pn = strings.Replace(pn, " Morning", " morning", -1)
-				pn = strings.Replace(pn, " Afternoon", " afternoon", -1)
-				pn = strings.Replace(pn, " Night", " night", -1)
-				periods = append(periods, pn)
+			fmt.Fprintf(&b, `<span class=%s>gusts %s</span>`,
+				stale(o), d.speed(units.Speed(*gust)))
+			station(&b, o, now)
+		case "pressure":
+			// The tendency is only meaningful with the
+			// pressure from the same station.
+			pres, o := pick(obs, func(o Observation) *float64 { return o.Pressure })
+			if pres == nil {
+				continue
 			}
-		}
-		texts := d.Parameters.WordedForecast.Text
-		if len(texts) != len(periods) {
-			c.Errorf("weather: len(texts) = %d, len(periods) = %d",
-				len(texts), len(periods))
-			continue
-		}
-		if len(texts) > 4 {
-			texts = texts[:4]
-		}
-		for i, text := range texts {
-			io.WriteString(w, `<div style="margin-bottom: 8px"><span class=header>`)
-			template.HTMLEscape(w, []byte(periods[i]))
-			io.WriteString(w, `:</span> `)
-
-			text = strings.Replace(text, "km/h", "km/\u2060h", -1)
-			spaceSubs := make(map[int]string)
-			matches := nbspRegexp.FindAllStringIndex(text, -1)
-			if len(matches) > 0 {
-				for i := 0; i < len(matches[0]); i += 2 {
-					spaceSubs[matches[0][i]] = "&nbsp;"
-				}
+			fmt.Fprintf(&b, `<span class=%s>%s%s</span>`,
+				stale(o), d.pressure(units.Pressure(*pres)), tendency(o.Tendency))
+			station(&b, o, now)
+		case "visibility":
+			vis, o := pick(obs, func(o Observation) *float64 { return o.Visibility })
+			if vis == nil {
+				continue
 			}
-			matches = thinspRegexp.FindAllStringIndex(text, -1)
-			if len(matches) > 0 {
-				for i := 0; i < len(matches[0]); i += 2 {
-					spaceSubs[matches[0][i]+1] = `<span style="white-space: nowrap">&thinsp;</span>`
-				}
+			x := units.NauticalMiles.Of(*vis)
+			fmt.Fprintf(&b, `<span class=%s>`, stale(o))
+			if ob := obscurity(x.In(units.Kilometres)); ob != "" {
+				fmt.Fprintf(&b, `%s, `, ob)
 			}
-			for i, ch := range text {
-				sub, ok := spaceSubs[i]
-				if ok {
-					io.WriteString(w, sub)
-				} else {
-					io.WriteString(w, string(ch))
-				}
+			fmt.Fprintf(&b, `visibility %s</span>`, d.distance(x))
+			station(&b, o, now)
+		case "dewpoint":
+			if dew == nil {
+				continue
 			}
+			fmt.Fprintf(&b, `<span class=%s>dew point %s</span>`,
+				stale(dewObs), d.temperature(units.Temperature(*dew)))
+			station(&b, dewObs, now)
+		default:
+			continue
+		}
+		parts = append(parts, b.String())
+	}
+	if len(parts) > 0 {
+		io.WriteString(w, `<div class=smaller>`)
+		io.WriteString(w, strings.Join(parts, ", "))
+		io.WriteString(w, `</div>`)
+	}
+
+	if show["outlook"] {
+		outlook(w, obs, windObs, now)
+	}
+	if show["trends"] {
+		_, presObs := pick(obs, func(o Observation) *float64 { return o.Pressure })
+		trends(w, c, d, now, tempObs.Station, windObs.Station, presObs.Station)
+	}
+}
 
-			io.WriteString(w, `</div>`)
+// outlook shows the Zambretti forecast from the pressure and tendency,
+// which must come from the same station, and the wind.
+func outlook(w io.Writer, obs []Observation, windObs Observation, now time.Time) {
+	pres, o := pick(obs, func(o Observation) *float64 {
+		if o.Tendency == nil {
+			return nil
 		}
+		return o.Pressure
+	})
+	if pres == nil {
+		return
 	}
+	fmt.Fprintf(w, `<div class=smaller>barometer: %s`,
+		meteo.Zambretti(*pres, *o.Tendency, windObs.WindDir, now, Lat))
+	station(w, o, now)
 	io.WriteString(w, `</div>`)
 }
+
+// tendency returns an arrow for a 3-hour pressure tendency in hPa:
+// rising, falling or, if nil or steady, nothing.
+func tendency(ptdy *float64) string {
+	switch {
+	case ptdy == nil:
+		return ""
+	case *ptdy >= meteo.SteadyPressure:
+		return "\u2009\u2191"
+	case *ptdy <= -meteo.SteadyPressure:
+		return "\u2009\u2193"
+	}
+	return ""
+}
+
+// obscurity names what limits a visibility in km, using the WMO
+// definitions: "fog" below 1 km and "mist" below 5 km.
+func obscurity(km float64) string {
+	switch {
+	case km < 1:
+		return "fog"
+	case km < 5:
+		return "mist"
+	}
+	return ""
+}
+
+// station notes which station an observation came from, and how long ago.
+func station(w io.Writer, o Observation, now time.Time) {
+	io.WriteString(w, `<span class=station>`)
+	template.HTMLEscape(w, []byte(o.Station))
+	io.WriteString(w, ` `)
+	io.WriteString(w, ago(now.Sub(o.Time)))
+	io.WriteString(w, `</span>`)
+}
+
+// recent returns the observations made within maxAge of now, or all of
+// them if maxAge is zero.
+func recent(obs []Observation, now time.Time, maxAge time.Duration) []Observation {
+	if maxAge == 0 {
+		return obs
+	}
+	var r []Observation
+	for _, o := range obs {
+		if now.Sub(o.Time) <= maxAge {
+			r = append(r, o)
+		}
+	}
+	return r
+}
+
+// ago describes an age, such as "6 min ago".
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d\u2009min ago", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%d\u2009h %d\u2009min ago", int(d.Hours()), int(d.Minutes())%60)
+}
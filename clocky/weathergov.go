@@ -0,0 +1,152 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"units"
+)
+
+// The api.weather.gov forecast for a point takes two steps: the point is
+// looked up to find the forecast office's grid square, and then the grid
+// square's forecasts are fetched.  https://www.weather.gov/documentation/services-web-api
+
+// weatherGovURL is where the API is.  Tests use a local server.
+var weatherGovURL = "https://api.weather.gov"
+
+// weatherGovData is what is cached for api.weather.gov: both forecasts,
+// as they were served.
+type weatherGovData struct {
+	Forecast, Hourly json.RawMessage
+}
+
+// getWeatherGov fetches the forecast and hourly forecast for a point from
+// the API at base, in SI units.
+func getWeatherGov(client *http.Client, base string, lat, lng float64) ([]byte, error) {
+	var point struct {
+		Properties struct {
+			Forecast, ForecastHourly string
+		}
+	}
+	// The API redirects coordinates with more precision than this.
+	url := fmt.Sprintf("%s/points/%.4f,%.4f", base, lat, lng)
+	if err := getJSON(client, url, &point); err != nil {
+		return nil, err
+	}
+	if point.Properties.Forecast == "" || point.Properties.ForecastHourly == "" {
+		return nil, fmt.Errorf("weathergov: no forecast for %s", url)
+	}
+	var data weatherGovData
+	if err := getJSON(client, point.Properties.Forecast+"?units=si", &data.Forecast); err != nil {
+		return nil, err
+	}
+	if err := getJSON(client, point.Properties.ForecastHourly+"?units=si", &data.Hourly); err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	// The API refuses requests without a User-Agent.
+	req.Header.Set("User-Agent", "clocky (https://github.com/shields/clocky)")
+	req.Header.Set("Accept", "application/geo+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("weathergov: bad status %d for %s", resp.StatusCode, url)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("weathergov: %s in %s", err, url)
+	}
+	return nil
+}
+
+// weatherGovForecast is the part of a forecast that is used.  The
+// forecast and the hourly forecast have the same form.
+type weatherGovForecast struct {
+	Properties struct {
+		Periods []struct {
+			Name                       string
+			StartTime                  time.Time
+			IsDaytime                  bool
+			Temperature                *float64
+			TemperatureUnit            string
+			ProbabilityOfPrecipitation struct {
+				Value *float64
+			}
+			Icon             string
+			ShortForecast    string
+			DetailedForecast string
+		}
+	}
+}
+
+// ParseWeatherGov parses the forecasts cached from api.weather.gov into
+// forecast periods, which have a high or a low, and hours, which have a
+// temperature.
+func ParseWeatherGov(data []byte) (periods, hours []Period, err error) {
+	var d weatherGovData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, nil, err
+	}
+	for _, f := range []struct {
+		raw    json.RawMessage
+		hourly bool
+		dst    *[]Period
+	}{{d.Forecast, false, &periods}, {d.Hourly, true, &hours}} {
+		var forecast weatherGovForecast
+		if err := json.Unmarshal(f.raw, &forecast); err != nil {
+			return nil, nil, fmt.Errorf("weathergov: %s", err)
+		}
+		for _, wp := range forecast.Properties.Periods {
+			p := Period{
+				Name:    periodName(wp.Name),
+				Start:   wp.StartTime,
+				PoP:     wp.ProbabilityOfPrecipitation.Value,
+				Summary: wp.ShortForecast,
+				Icon:    wp.Icon,
+				Text:    wp.DetailedForecast,
+			}
+			if wp.Temperature != nil {
+				scale := units.Celsius
+				if wp.TemperatureUnit == "F" {
+					scale = units.Fahrenheit
+				}
+				t := scale.Of(*wp.Temperature)
+				switch {
+				case f.hourly:
+					p.Temp = &t
+				case wp.IsDaytime:
+					p.High = &t
+				default:
+					p.Low = &t
+				}
+			}
+			*f.dst = append(*f.dst, p)
+		}
+	}
+	return periods, hours, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// weatherGovServer serves the recorded responses in testdata/weathergov
+// as api.weather.gov would.
+func weatherGovServer(t *testing.T) *httptest.Server {
+	files := map[string]string{
+		"/points/37.7900,-122.4200":              "points.json",
+		"/gridpoints/MTR/85,106/forecast":        "forecast.json",
+		"/gridpoints/MTR/85,106/forecast/hourly": "hourly.json",
+	}
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			http.Error(w, "No User-Agent", http.StatusForbidden)
+			return
+		}
+		name, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if name != "points.json" && r.FormValue("units") != "si" {
+			t.Errorf("%s requested without units=si", r.URL)
+		}
+		b, err := ioutil.ReadFile("testdata/weathergov/" + name)
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Write([]byte(strings.Replace(string(b), "https://api.weather.gov", ts.URL, -1)))
+	}))
+	return ts
+}
+
+func TestWeatherGov(t *testing.T) {
+	ts := weatherGovServer(t)
+	defer ts.Close()
+
+	data, err := getWeatherGov(http.DefaultClient, ts.URL, 37.79, -122.42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periods, hours, err := ParseWeatherGov(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(periods) != 6 {
+		t.Fatalf("got %d periods, want 6", len(periods))
+	}
+	p := periods[0]
+	if p.Name != "Tonight" || p.High != nil || p.Low == nil || *p.Low != 9 ||
+		p.PoP == nil || *p.PoP != 20 || p.Summary != "Slight Chance Light Rain" {
+		t.Errorf("periods[0] = %+v", p)
+	}
+	if !strings.HasPrefix(p.Text, "A slight chance of rain after 4am.") {
+		t.Errorf("periods[0].Text = %q", p.Text)
+	}
+	p = periods[2]
+	if p.Name != "Wednesday night" {
+		t.Errorf("periods[2].Name = %q, want Wednesday night", p.Name)
+	}
+	p = periods[3]
+	if p.High == nil || *p.High != 12 || p.PoP != nil {
+		t.Errorf("periods[3] = %+v, want high 12 and no PoP", p)
+	}
+
+	if len(hours) != 48 {
+		t.Fatalf("got %d hours, want 48", len(hours))
+	}
+	start := time.Date(2024, 1, 10, 2, 0, 0, 0, time.UTC)
+	for i, h := range hours {
+		if want := start.Add(time.Duration(i) * time.Hour); !h.Start.Equal(want) {
+			t.Errorf("hours[%d].Start = %v, want %v", i, h.Start, want)
+		}
+		if h.Temp == nil || h.High != nil || h.Low != nil || h.PoP == nil {
+			t.Errorf("hours[%d] = %+v, want only a temperature and PoP", i, h)
+		}
+	}
+	if *hours[15].PoP != 70 {
+		t.Errorf("hours[15].PoP = %g, want 70", *hours[15].PoP)
+	}
+}
+
+func TestWeatherGovErrors(t *testing.T) {
+	ts := weatherGovServer(t)
+	defer ts.Close()
+	// No grid square here.
+	if _, err := getWeatherGov(http.DefaultClient, ts.URL, 0, 0); err == nil {
+		t.Errorf("getWeatherGov for 0, 0 succeeded, want error")
+	}
+	if _, _, err := ParseWeatherGov([]byte("{")); err == nil {
+		t.Errorf("ParseWeatherGov of bad JSON succeeded, want error")
+	}
+}
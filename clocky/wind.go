@@ -0,0 +1,71 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"io"
+
+	"units"
+)
+
+var compassPoints = [...]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// Cardinal names the nearest of the 16 points of the compass to a
+// direction in degrees clockwise from north, or returns "" if it is
+// outside [0, 360].  Each point covers 22.5°, centred on it.
+func Cardinal(degrees int) string {
+	if degrees < 0 || degrees > 360 {
+		return ""
+	}
+	// Each point is centred on a multiple of 22.5°, which is 90/4.
+	return compassPoints[(degrees*4+45)/90%16]
+}
+
+// Size of the wind dial, in pixels.
+const dialSize = 80
+
+// windDial draws a compass dial with a pointer on the rim at the
+// direction the wind is from, pointing the way it blows, and the
+// sustained and gust speeds inside.  The direction and gust may be nil.
+// It is drawn in the current text color, so that it can be shown as
+// stale.
+func windDial(w io.Writer, d *Display, dir *float64, speed units.Speed, gust *units.Speed) {
+	const r = dialSize/2 - 8
+	u := d.Units.Speed
+	fmt.Fprintf(w, `<svg width=%d height=%d viewBox="%d %d %d %d" style="vertical-align: middle">`,
+		dialSize, dialSize, -dialSize/2, -dialSize/2, dialSize, dialSize)
+	fmt.Fprintf(w, `<circle r=%d fill=none stroke=currentColor stroke-width=2 />`, r)
+	// Ticks at north, east, south and west.
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(w, `<line y1=%d y2=%d stroke=currentColor transform="rotate(%d)" />`,
+			-r, -r+4, 90*i)
+	}
+	if dir != nil {
+		fmt.Fprintf(w, `<polygon points="0,%d -6,%d 6,%d" fill=currentColor transform="rotate(%.0f)" />`,
+			-r+8, -r-7, -r-7, *dir)
+	}
+	fmt.Fprintf(w, `<text y=0 text-anchor=middle font-size=18 fill=currentColor>%.0f</text>`,
+		speed.In(u))
+	fmt.Fprintf(w, `<text y=9 text-anchor=middle font-size=8 fill=currentColor>%s</text>`, u)
+	if gust != nil {
+		fmt.Fprintf(w, `<text y=20 text-anchor=middle font-size=9 fill=currentColor>G%.0f</text>`,
+			gust.In(u))
+	}
+	io.WriteString(w, `</svg>`)
+}
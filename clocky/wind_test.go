@@ -0,0 +1,82 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"units"
+)
+
+func TestCardinal(t *testing.T) {
+	// Every sector, from its first whole degree to its last.
+	sectors := []struct {
+		from, to int
+		want     string
+	}{
+		{0, 11, "N"},
+		{12, 33, "NNE"},
+		{34, 56, "NE"},
+		{57, 78, "ENE"},
+		{79, 101, "E"},
+		{102, 123, "ESE"},
+		{124, 146, "SE"},
+		{147, 168, "SSE"},
+		{169, 191, "S"},
+		{192, 213, "SSW"},
+		{214, 236, "SW"},
+		{237, 258, "WSW"},
+		{259, 281, "W"},
+		{282, 303, "WNW"},
+		{304, 326, "NW"},
+		{327, 348, "NNW"},
+		{349, 360, "N"},
+	}
+	for _, s := range sectors {
+		for deg := s.from; deg <= s.to; deg++ {
+			if got := Cardinal(deg); got != s.want {
+				t.Errorf("Cardinal(%d) = %q, want %q", deg, got, s.want)
+			}
+		}
+	}
+	for _, deg := range []int{-1, 361} {
+		if got := Cardinal(deg); got != "" {
+			t.Errorf("Cardinal(%d) = %q, want \"\"", deg, got)
+		}
+	}
+}
+
+func TestWindDial(t *testing.T) {
+	d := &Display{Units: units.Marine}
+	dir := 90.0
+	gust := units.Knots.Of(17)
+	var b bytes.Buffer
+	windDial(&b, d, &dir, units.Knots.Of(12), &gust)
+	s := b.String()
+	for _, want := range []string{`rotate(90)" />`, ">12</text>", ">kn</text>", ">G17</text>"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("windDial: %q not in %s", want, s)
+		}
+	}
+
+	b.Reset()
+	windDial(&b, d, nil, units.Knots.Of(12), nil)
+	s = b.String()
+	if strings.Contains(s, "<polygon") || strings.Contains(s, ">G") {
+		t.Errorf("windDial without direction or gust: %s", s)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"appengine"
+
+	"solar"
+)
+
+// WorldClocks shows the time in each of the display's other places,
+// with the difference in date from here and whether the sun is up there.
+// The browser ticks the times and dates, as it does the clock.
+func WorldClocks(w io.Writer, c appengine.Context, d *Display) {
+	here, err := time.LoadLocation(d.Zone)
+	if err != nil {
+		c.Errorf("%s", err)
+		return
+	}
+	now := time.Now()
+	shown := 0
+	for _, wc := range d.WorldClocks {
+		there, err := time.LoadLocation(wc.Zone)
+		if err != nil {
+			c.Errorf("%s", err)
+			continue
+		}
+		t := now.In(there)
+		_, offset := t.Zone()
+		worldTmpl.Execute(w, map[string]interface{}{
+			"Label":  wc.Label,
+			"Time":   t.Format(d.TimeFormat()),
+			"Days":   dayOffset(now.In(here), t),
+			"Offset": offset,
+			"Light":  light(now, wc.Lat, wc.Lng),
+		})
+		shown++
+	}
+	if shown > 0 {
+		_, offset := now.In(here).Zone()
+		worldTickTmpl.Execute(w, map[string]interface{}{
+			"Offset": offset,
+			"Hour24": d.Hour24,
+		})
+	}
+}
+
+// light returns "day" from civil dawn to civil dusk at a place, when
+// it is light enough to be up and about, and "night" otherwise.
+func light(t time.Time, lat, lng float64) string {
+	if _, el := solar.Position(t, lat, lng); el > -solar.Civil {
+		return "day"
+	}
+	return "night"
+}
+
+// dayOffset describes how many days the date at there is ahead of or
+// behind the date at here, such as "+1", or "" if they are the same.
+func dayOffset(here, there time.Time) string {
+	date := func(t time.Time) time.Time {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	days := int(date(there).Sub(date(here)).Hours() / 24)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("+%d", days)
+	case days < 0:
+		return fmt.Sprintf("\u2212%d", -days)
+	}
+	return ""
+}
+
+var worldTmpl = template.Must(template.New("world").Parse(`
+ <div class=world data-offset="{{.Offset}}"><span class={{.Light}} title={{.Light}}></span> {{.Label}}
+  <span class="header worldtime">{{.Time}}</span> <span class="smaller worlddays">{{.Days}}</span></div>
+`))
+
+// worldTickTmpl updates the world clocks every minute, from the server's
+// time and each place's offset from UTC, in seconds, as when the page
+// was rendered.  Whether it is day or night waits for the page to be
+// reloaded, as it is at least hourly.
+var worldTickTmpl = template.Must(template.New("worldtick").Parse(`
+ <script>
+  (function() {
+   var here = {{.Offset}} * 1000, hour24 = {{.Hour24}};
+   var clocks = document.querySelectorAll(".world");
+   function pad(n) { return n < 10 ? "0" + n : "" + n; }
+   function day(ms) { return Math.floor(ms / 86400000); }
+   function tick() {
+    var now = serverTime();
+    for (var i = 0; i < clocks.length; i++) {
+     var offset = parseInt(clocks[i].getAttribute("data-offset"), 10) * 1000;
+     var t = new Date(now + offset);
+     var h = t.getUTCHours();
+     var text = (hour24 ? pad(h) : (h % 12 || 12)) + ":" + pad(t.getUTCMinutes()) +
+      (hour24 ? "" : (h < 12 ? "\u2009am" : "\u2009pm"));
+     var days = day(now + offset) - day(now + here);
+     clocks[i].querySelector(".worldtime").innerHTML = text;
+     clocks[i].querySelector(".worlddays").innerHTML =
+      days > 0 ? "+" + days : days < 0 ? "\u2212" + -days : "";
+    }
+    setTimeout(tick, 60010 - now % 60000);
+   }
+   tick();
+  })();
+ </script>
+`))
@@ -0,0 +1,90 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocky
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayOffset(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		now         time.Time
+		here, there *time.Location
+		want        string
+	}{
+		// 23:30 on Tuesday in San Francisco is 16:30 on Wednesday
+		// in Tokyo, and half an hour later it is still Wednesday.
+		{time.Date(2012, 1, 10, 23, 30, 0, 0, la), la, tokyo, "+1"},
+		{time.Date(2012, 1, 11, 0, 30, 0, 0, la), la, tokyo, ""},
+		{time.Date(2012, 1, 11, 6, 59, 0, 0, la), la, tokyo, ""},
+		{time.Date(2012, 1, 11, 7, 0, 0, 0, la), la, tokyo, "+1"},
+		// From Tokyo, San Francisco is a day behind until 17:00.
+		{time.Date(2012, 1, 11, 16, 59, 0, 0, tokyo), tokyo, la, "−1"},
+		{time.Date(2012, 1, 11, 17, 0, 0, 0, tokyo), tokyo, la, ""},
+		{time.Date(2012, 1, 11, 0, 30, 0, 0, tokyo), tokyo, la, "−1"},
+		{time.Date(2012, 1, 11, 15, 59, 0, 0, la), la, london, ""},
+		{time.Date(2012, 1, 11, 16, 0, 0, 0, la), la, london, "+1"},
+		{time.Date(2012, 1, 11, 12, 0, 0, 0, la), la, la, ""},
+		// Across the end of a month and a year.
+		{time.Date(2012, 12, 31, 18, 0, 0, 0, la), la, tokyo, "+1"},
+		{time.Date(2013, 1, 1, 8, 0, 0, 0, tokyo), tokyo, la, "−1"},
+	}
+	for _, tt := range cases {
+		if got := dayOffset(tt.now.In(tt.here), tt.now.In(tt.there)); got != tt.want {
+			t.Errorf("%s in %s: got %q, want %q", tt.now.In(tt.here), tt.there, got, tt.want)
+		}
+	}
+}
+
+func TestLight(t *testing.T) {
+	cases := []struct {
+		when     string
+		lat, lng float64
+		want     string
+	}{
+		// Noon and midnight in San Francisco, and at those times
+		// in Tokyo.
+		{"2012-01-11T20:00:00Z", 37.79, -122.42, "day"},
+		{"2012-01-11T08:00:00Z", 37.79, -122.42, "night"},
+		{"2012-01-11T20:00:00Z", 35.69, 139.69, "night"},
+		{"2012-01-11T08:00:00Z", 35.69, 139.69, "day"},
+		// A quarter of an hour after sunset in San Francisco, at
+		// about 17:10 PST, is still civil twilight.
+		{"2012-01-12T01:25:00Z", 37.79, -122.42, "day"},
+		{"2012-01-12T02:30:00Z", 37.79, -122.42, "night"},
+	}
+	for _, tt := range cases {
+		when, err := time.Parse(time.RFC3339, tt.when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := light(when, tt.lat, tt.lng); got != tt.want {
+			t.Errorf("at %g, %g at %s: got %s, want %s", tt.lat, tt.lng, tt.when, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+ lunar computes the phase of the moon and the times of moonrise and
+ moonset for any location.
+
+ The position of the moon is calculated from the principal periodic terms
+ of the ELP-2000/82 lunar theory, as given in Jean Meeus, Astronomical
+ Algorithms, chapter 47.  Using only the largest terms, positions are good
+ to about 0.01°, and rise and set times to about a minute.
+*/
+package lunar
+
+import (
+	"math"
+	"time"
+)
+
+// Phase returns the age of the moon as a fraction of its synodic
+// month: 0 at new moon, 0.25 at first quarter, 0.5 at full moon and
+// 0.75 at last quarter.
+func Phase(t time.Time) float64 {
+	return elongation(t) / 360
+}
+
+// Illumination returns the fraction of the moon's disk which is lit,
+// from 0 to 1.
+func Illumination(t time.Time) float64 {
+	jc := century(t)
+	d := rad(297.8501921 + 445267.1114034*jc)
+	m := rad(357.5291092 + 35999.0502909*jc)
+	mp := rad(134.9633964 + 477198.8675055*jc)
+	// Meeus (48.4): the phase angle, sun-moon-earth.
+	i := math.Pi - d - rad(6.289*math.Sin(mp)-2.100*math.Sin(m)+
+		1.274*math.Sin(2*d-mp)+0.658*math.Sin(2*d)+
+		0.214*math.Sin(2*mp)+0.110*math.Sin(d))
+	return (1 + math.Cos(i)) / 2
+}
+
+// The moon's elongation changes by about 12.2° a day, so a principal
+// phase is named when it is within half a day.
+const principal = 6.1
+
+// PhaseName returns the name of the moon's phase, such as "waxing
+// crescent" or "full moon".  A principal phase (new, first quarter, full
+// and last quarter) is named only within about twelve hours of its exact
+// time.
+func PhaseName(t time.Time) string {
+	e := elongation(t)
+	switch {
+	case e < principal || e >= 360-principal:
+		return "new moon"
+	case e < 90-principal:
+		return "waxing crescent"
+	case e < 90+principal:
+		return "first quarter"
+	case e < 180-principal:
+		return "waxing gibbous"
+	case e < 180+principal:
+		return "full moon"
+	case e < 270-principal:
+		return "waning gibbous"
+	case e < 270+principal:
+		return "last quarter"
+	}
+	return "waning crescent"
+}
+
+// Rise returns the time of the next moonrise.  The moon rises about
+// fifty minutes later each day, so on about one day a month it does
+// not rise at all; near the poles it may not rise for two weeks.  The
+// result is in the same location as t.  If the moon does not rise within
+// a month, the zero Time is returned.
+func Rise(t time.Time, lat, lng float64) time.Time {
+	return next(t, lat, lng, true)
+}
+
+// Set returns the time of the next moonset, as Rise does for moonrise.
+func Set(t time.Time, lat, lng float64) time.Time {
+	return next(t, lat, lng, false)
+}
+
+// next returns the first time not before t at which the upper limb of
+// the moon crosses the horizon, going up if rising is set and down
+// otherwise.
+func next(t time.Time, lat, lng float64, rising bool) time.Time {
+	// The moon's altitude changes by at most 15° an hour, so an
+	// hourly search only misses crossings that graze the horizon.
+	const step = time.Hour
+	a := t.Truncate(time.Second)
+	fa := altitude(a, lat, lng)
+	for i := 0; i < 24*31; i++ {
+		b := a.Add(step)
+		fb := altitude(b, lat, lng)
+		if (fa < 0) != (fb < 0) && (fa < 0) == rising {
+			for b.Sub(a) > time.Second {
+				m := a.Add(b.Sub(a) / 2)
+				if fm := altitude(m, lat, lng); (fm < 0) == (fa < 0) {
+					a, fa = m, fm
+				} else {
+					b = m
+				}
+			}
+			return b.Truncate(time.Second).In(t.Location())
+		}
+		a, fa = b, fb
+	}
+	return time.Time{}
+}
+
+// altitude returns the geocentric altitude of the center of the moon
+// above its altitude at moonrise, in degrees.
+func altitude(t time.Time, lat, lng float64) float64 {
+	lambda, beta, dist := position(t)
+	jc := century(t)
+	eps := rad(23.4392911 - 0.0130042*jc)
+	ra := math.Atan2(math.Sin(lambda)*math.Cos(eps)-math.Tan(beta)*math.Sin(eps),
+		math.Cos(lambda))
+	decl := math.Asin(math.Sin(beta)*math.Cos(eps) +
+		math.Cos(beta)*math.Sin(eps)*math.Sin(lambda))
+
+	// Greenwich mean sidereal time, Meeus (12.4).
+	gmst := 280.46061837 + 360.98564736629*(julian(t)-2451545) +
+		0.000387933*jc*jc
+	ha := rad(gmst+lng) - ra
+	phi := rad(lat)
+	h := math.Asin(math.Sin(phi)*math.Sin(decl) +
+		math.Cos(phi)*math.Cos(decl)*math.Cos(ha))
+
+	// At moonrise, the center of the moon is below the horizon by
+	// refraction and the moon's semidiameter, but raised by the
+	// moon's parallax, which is large since it is so close.
+	parallax := math.Asin(6378.14 / dist)
+	h0 := 0.7275*deg(parallax) - 0.5667
+	return deg(h) - h0
+}
+
+// elongation returns the difference between the ecliptic longitudes of
+// the moon and the sun, from 0 to 360°.
+func elongation(t time.Time) float64 {
+	lambda, _, _ := position(t)
+	e := math.Mod(deg(lambda)-sunLongitude(t), 360)
+	if e < 0 {
+		e += 360
+	}
+	return e
+}
+
+// sunLongitude returns the geometric ecliptic longitude of the sun in
+// degrees, to about 0.01°.
+func sunLongitude(t time.Time) float64 {
+	jc := century(t)
+	l0 := 280.46646 + jc*(36000.76983+jc*0.0003032)
+	m := rad(357.52911 + jc*(35999.05029-0.0001537*jc))
+	c := math.Sin(m)*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(2*m)*(0.019993-0.000101*jc) +
+		math.Sin(3*m)*0.000289
+	return l0 + c
+}
+
+// position returns the geocentric ecliptic longitude and latitude of
+// the moon, in radians, and its distance in km.
+func position(t time.Time) (lambda, beta, dist float64) {
+	jc := century(t)
+	lp := rad(218.3164477 + 481267.88123421*jc)
+	d := rad(297.8501921 + 445267.1114034*jc)
+	m := rad(357.5291092 + 35999.0502909*jc)
+	mp := rad(134.9633964 + 477198.8675055*jc)
+	f := rad(93.2720950 + 483202.0175233*jc)
+	e := 1 - 0.002516*jc
+	a1 := rad(119.75 + 131.849*jc)
+	a2 := rad(53.09 + 479264.290*jc)
+	a3 := rad(313.45 + 481266.484*jc)
+
+	// Terms involving the sun's anomaly decrease with the
+	// eccentricity of the earth's orbit.
+	ecc := func(tm int) float64 {
+		switch tm {
+		case 1, -1:
+			return e
+		case 2, -2:
+			return e * e
+		}
+		return 1
+	}
+
+	var sl, sr, sb float64
+	for _, tt := range lrTerms {
+		arg := float64(tt.d)*d + float64(tt.m)*m + float64(tt.mp)*mp + float64(tt.f)*f
+		sl += ecc(tt.m) * tt.l * math.Sin(arg)
+		sr += ecc(tt.m) * tt.r * math.Cos(arg)
+	}
+	for _, tt := range bTerms {
+		arg := float64(tt.d)*d + float64(tt.m)*m + float64(tt.mp)*mp + float64(tt.f)*f
+		sb += ecc(tt.m) * tt.b * math.Sin(arg)
+	}
+	sl += 3958*math.Sin(a1) + 1962*math.Sin(lp-f) + 318*math.Sin(a2)
+	sb += -2235*math.Sin(lp) + 382*math.Sin(a3) + 175*math.Sin(a1-f) +
+		175*math.Sin(a1+f) + 127*math.Sin(lp-mp) - 115*math.Sin(lp+mp)
+
+	lambda = math.Mod(lp+rad(sl/1e6), 2*math.Pi)
+	if lambda < 0 {
+		lambda += 2 * math.Pi
+	}
+	beta = rad(sb / 1e6)
+	dist = 385000.56 + sr/1000
+	return lambda, beta, dist
+}
+
+// Meeus table 47.A: the multiples of D, M, M' and F, with coefficients
+// for longitude in 0.000001° and distance in 0.001 km.
+var lrTerms = []struct {
+	d, m, mp, f int
+	l, r        float64
+}{
+	{0, 0, 1, 0, 6288774, -20905355},
+	{2, 0, -1, 0, 1274027, -3699111},
+	{2, 0, 0, 0, 658314, -2955968},
+	{0, 0, 2, 0, 213618, -569925},
+	{0, 1, 0, 0, -185116, 48888},
+	{0, 0, 0, 2, -114332, -3149},
+	{2, 0, -2, 0, 58793, 246158},
+	{2, -1, -1, 0, 57066, -152138},
+	{2, 0, 1, 0, 53322, -170733},
+	{2, -1, 0, 0, 45758, -204586},
+	{0, 1, -1, 0, -40923, -129620},
+	{1, 0, 0, 0, -34720, 108743},
+	{0, 1, 1, 0, -30383, 104755},
+	{2, 0, 0, -2, 15327, 10321},
+	{0, 0, 1, 2, -12528, 0},
+	{0, 0, 1, -2, 10980, 79661},
+	{4, 0, -1, 0, 10675, -34782},
+	{0, 0, 3, 0, 10034, -23210},
+	{4, 0, -2, 0, 8548, -21636},
+	{2, 1, -1, 0, -7888, 24208},
+	{2, 1, 0, 0, -6766, 30824},
+	{1, 0, -1, 0, -5163, -8379},
+	{1, 1, 0, 0, 4987, -16675},
+	{2, -1, 1, 0, 4036, -12831},
+	{2, 0, 2, 0, 3994, -10445},
+	{4, 0, 0, 0, 3861, -11650},
+	{2, 0, -3, 0, 3665, 14403},
+	{0, 1, -2, 0, -2689, -7003},
+	{2, 0, -1, 2, -2602, 0},
+	{2, -1, -2, 0, 2390, 10056},
+	{1, 0, 1, 0, -2348, 6322},
+	{2, -2, 0, 0, 2236, -9884},
+	{0, 1, 2, 0, -2120, 5751},
+	{0, 2, 0, 0, -2069, 0},
+}
+
+// Meeus table 47.B: the multiples of D, M, M' and F, with coefficients
+// for latitude in 0.000001°.
+var bTerms = []struct {
+	d, m, mp, f int
+	b           float64
+}{
+	{0, 0, 0, 1, 5128122},
+	{0, 0, 1, 1, 280602},
+	{0, 0, 1, -1, 277693},
+	{2, 0, 0, -1, 173237},
+	{2, 0, -1, 1, 55413},
+	{2, 0, -1, -1, 46271},
+	{2, 0, 0, 1, 32573},
+	{0, 0, 2, 1, 17198},
+	{2, 0, 1, -1, 9266},
+	{0, 0, 2, -1, 8822},
+	{2, -1, 0, -1, 8216},
+	{2, 0, -2, -1, 4324},
+	{2, 0, 1, 1, 4200},
+	{2, 1, 0, -1, -3359},
+	{2, -1, -1, 1, 2463},
+	{2, -1, 0, 1, 2211},
+	{2, -1, -1, -1, 2065},
+	{0, 1, -1, -1, -1870},
+	{4, 0, -1, -1, 1828},
+	{0, 1, 0, 1, -1794},
+}
+
+// century returns the number of Julian centuries since J2000.0.
+func century(t time.Time) float64 {
+	return (julian(t) - 2451545) / 36525
+}
+
+// julian returns the Julian day number of t.
+func julian(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+func deg(r float64) float64 { return r * 180 / math.Pi }
@@ -0,0 +1,212 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lunar
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// Meeus, example 47.a.  The example is for 0h dynamical time, about a
+// minute after 0h UT, during which the moon moves about 0.01°.
+func TestPosition(t *testing.T) {
+	lambda, beta, dist := position(time.Date(1992, 4, 12, 0, 0, 0, 0, time.UTC))
+	if got, want := deg(lambda), 133.162655; math.Abs(got-want) > 0.02 {
+		t.Errorf("longitude: got %g, want %g", got, want)
+	}
+	if got, want := deg(beta), -3.229126; math.Abs(got-want) > 0.01 {
+		t.Errorf("latitude: got %g, want %g", got, want)
+	}
+	if want := 368409.7; math.Abs(dist-want) > 20 {
+		t.Errorf("distance: got %g, want %g", dist, want)
+	}
+}
+
+// Principal phases for 2012 from the USNO, in UT.
+func TestPhase(t *testing.T) {
+	cases := []struct {
+		when  string
+		phase float64
+	}{
+		{"2012-01-01T06:15:00Z", 0.25},
+		{"2012-01-09T07:30:00Z", 0.5},
+		{"2012-01-16T09:08:00Z", 0.75},
+		{"2012-01-23T07:39:00Z", 0},
+		{"2012-05-06T03:35:00Z", 0.5},
+		{"2012-05-20T23:47:00Z", 0},
+		{"2012-08-31T13:58:00Z", 0.5},
+		{"2012-11-13T22:08:00Z", 0},
+	}
+	for _, tt := range cases {
+		when, err := time.Parse(time.RFC3339, tt.when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Ten minutes of the moon's motion.
+		got := Phase(when)
+		if d := math.Abs(math.Remainder(got-tt.phase, 1)); d > 10./(29.53*24*60) {
+			t.Errorf("phase at %s: got %.5f, want %g", tt.when, got, tt.phase)
+		}
+		want := map[float64]string{
+			0: "new moon", 0.25: "first quarter",
+			0.5: "full moon", 0.75: "last quarter",
+		}[tt.phase]
+		for _, d := range []time.Duration{-9 * time.Hour, 0, 9 * time.Hour} {
+			if name := PhaseName(when.Add(d)); name != want {
+				t.Errorf("phase name at %s%+v: got %q, want %q", tt.when, d, name, want)
+			}
+		}
+		if ill := Illumination(when); math.Abs(ill-tt.phase*2) > 0.01 &&
+			math.Abs(ill-(1-math.Abs(1-tt.phase*2))) > 0.01 {
+			t.Errorf("illumination at %s: got %g", tt.when, ill)
+		}
+	}
+
+	when := time.Date(2012, 1, 5, 0, 0, 0, 0, time.UTC)
+	if name := PhaseName(when); name != "waxing gibbous" {
+		t.Errorf("phase name at %s: got %q, want waxing gibbous", when, name)
+	}
+}
+
+const sfLat, sfLng = 37.79, -122.42
+
+func TestRiseSet(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	when := time.Date(2012, 1, 1, 0, 0, 0, 0, pst)
+	for i := 0; i < 60; i++ {
+		rise := Rise(when, sfLat, sfLng)
+		set := Set(when, sfLat, sfLng)
+		for _, tt := range []time.Time{rise, set} {
+			if tt.Before(when) || tt.Sub(when) > 25*time.Hour {
+				t.Fatalf("after %s: rise %s, set %s", when, rise, set)
+			}
+			if a := altitude(tt, sfLat, sfLng); math.Abs(a) > 0.01 {
+				t.Errorf("altitude at %s is %g", tt, a)
+			}
+		}
+		if rise.Location() != pst {
+			t.Errorf("rise in %s, want %s", rise.Location(), pst)
+		}
+		if a := altitude(rise.Add(time.Minute), sfLat, sfLng); a < 0 {
+			t.Errorf("moon not rising at %s", rise)
+		}
+		if a := altitude(set.Add(time.Minute), sfLat, sfLng); a > 0 {
+			t.Errorf("moon not setting at %s", set)
+		}
+		when = when.Add(12 * time.Hour)
+	}
+
+	// The full moon rises at about sunset, opposite the sun.
+	full := time.Date(2012, 1, 9, 0, 0, 0, 0, pst)
+	if rise := Rise(full, sfLat, sfLng); rise.Hour() != 16 && rise.Hour() != 17 {
+		t.Errorf("full moon rises at %s, want around sunset", rise)
+	}
+}
+
+// upperLimb returns the apparent altitude of the moon's upper limb, in
+// degrees, without altitude's approximation of the standard altitude:
+// the moon's position is made topocentric as in Meeus, chapters 11 and
+// 40, and 34' is allowed for refraction at the horizon, as the USNO does.
+// It shares position and the sidereal time with the code under test, so
+// it can't catch errors in those.
+func upperLimb(t time.Time, lat, lng float64) float64 {
+	lambda, beta, dist := position(t)
+	eps := rad(23.4392911 - 0.0130042*century(t))
+	ra := math.Atan2(math.Sin(lambda)*math.Cos(eps)-math.Tan(beta)*math.Sin(eps),
+		math.Cos(lambda))
+	decl := math.Asin(math.Sin(beta)*math.Cos(eps) +
+		math.Cos(beta)*math.Sin(eps)*math.Sin(lambda))
+	gmst := 280.46061837 + 360.98564736629*(julian(t)-2451545)
+	ha := rad(gmst+lng) - ra
+
+	// The observer is at sea level on the ellipsoid.
+	phi := rad(lat)
+	u := math.Atan(0.99664719 * math.Tan(phi))
+	rhoSin, rhoCos := 0.99664719*math.Sin(u), math.Cos(u)
+	sinPar := 6378.14 / dist
+	dra := math.Atan2(-rhoCos*sinPar*math.Sin(ha), math.Cos(decl)-rhoCos*sinPar*math.Cos(ha))
+	tdecl := math.Atan2((math.Sin(decl)-rhoSin*sinPar)*math.Cos(dra),
+		math.Cos(decl)-rhoCos*sinPar*math.Cos(ha))
+	h := math.Asin(math.Sin(phi)*math.Sin(tdecl) +
+		math.Cos(phi)*math.Cos(tdecl)*math.Cos(ha-dra))
+
+	semidiameter := deg(math.Asin(1737.4 / dist))
+	return deg(h) + semidiameter + 34./60
+}
+
+// Rise and Set agree to within two minutes with the moon's upper limb
+// crossing the horizon, worked out another way, through a year of
+// moonrises and moonsets in San Francisco and near the Arctic Circle.
+//
+// TODO: Check Rise and Set against the USNO's table of moonrise and
+// moonset for San Francisco in 2012, to within two minutes, as
+// solar_test.go checks the sun against its table.  Only that catches
+// errors in position and the sidereal time.
+func TestRiseSetTopocentric(t *testing.T) {
+	const tolerance = 2 * time.Minute
+	for _, place := range []struct{ lat, lng float64 }{{sfLat, sfLng}, {65.0, -147.7}} {
+		when := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+		for when.Year() == 2012 {
+			rise := Rise(when, place.lat, place.lng)
+			set := Set(when, place.lat, place.lng)
+			if rise.IsZero() || set.IsZero() {
+				t.Fatalf("no rise or set after %s at %g, %g", when, place.lat, place.lng)
+			}
+			if upperLimb(rise.Add(-tolerance), place.lat, place.lng) > 0 ||
+				upperLimb(rise.Add(tolerance), place.lat, place.lng) < 0 {
+				t.Errorf("moonrise at %g, %g given as %s", place.lat, place.lng, rise)
+			}
+			if upperLimb(set.Add(-tolerance), place.lat, place.lng) < 0 ||
+				upperLimb(set.Add(tolerance), place.lat, place.lng) > 0 {
+				t.Errorf("moonset at %g, %g given as %s", place.lat, place.lng, set)
+			}
+			if set.Before(rise) {
+				when = set.Add(time.Minute)
+			} else {
+				when = rise.Add(time.Minute)
+			}
+		}
+	}
+}
+
+// The moon rises about 50 minutes later each day, so it doesn't rise on
+// the day it would have risen just after midnight, around last quarter,
+// and similarly doesn't set on a day around first quarter.  In January
+// 2012 the quarters were on the 16th at 01:08 PST and the 30th at 20:10.
+func TestNoRiseSet(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	cases := []struct {
+		day       int
+		rise, set bool
+	}{
+		{14, true, true},
+		{15, false, true},
+		{16, true, true},
+		{28, true, true},
+		{29, true, false},
+		{30, true, true},
+	}
+	for _, tt := range cases {
+		day := time.Date(2012, 1, tt.day, 0, 0, 0, 0, pst)
+		end := day.AddDate(0, 0, 1)
+		if rise := Rise(day, sfLat, sfLng).Before(end); rise != tt.rise {
+			t.Errorf("moonrise on %s is %v, want %v", day.Format("Jan 2"), rise, tt.rise)
+		}
+		if set := Set(day, sfLat, sfLng).Before(end); set != tt.set {
+			t.Errorf("moonset on %s is %v, want %v", day.Format("Jan 2"), set, tt.set)
+		}
+	}
+}
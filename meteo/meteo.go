@@ -0,0 +1,110 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+ meteo derives quantities from weather observations: relative humidity
+ and the apparent temperatures that describe how the weather feels.
+
+ Temperatures are in degrees Celsius and wind speeds in km/h, as the
+ formulas are usually given.
+*/
+package meteo
+
+import "math"
+
+// RelativeHumidity returns the relative humidity in percent for an air
+// temperature and dew point, using the Magnus formula with the
+// coefficients of Alduchov and Eskridge (1996).
+func RelativeHumidity(temp, dew float64) float64 {
+	return 100 * math.Exp(17.625*dew/(243.04+dew)) / math.Exp(17.625*temp/(243.04+temp))
+}
+
+// vaporPressure returns the water vapor pressure in hPa for an air
+// temperature and relative humidity.
+func vaporPressure(temp, rh float64) float64 {
+	return rh / 100 * 6.105 * math.Exp(17.27*temp/(237.7+temp))
+}
+
+// WindChill returns the wind chill (2001 North American formula) for an
+// air temperature and wind speed, or nil if it is too warm or not windy
+// enough for it to be defined.
+func WindChill(temp, speed float64) *float64 {
+	if temp > 10 || speed <= 4.8 {
+		return nil
+	}
+	chill := 13.12 + 0.6215*temp - 11.37*math.Pow(speed, 0.16) + 0.3965*temp*math.Pow(speed, 0.16)
+	return &chill
+}
+
+// HeatIndex returns the US National Weather Service heat index for an
+// air temperature and relative humidity, or nil if it is too cool for it
+// to be defined.  It uses the Rothfusz regression, with the NWS's
+// adjustments at the extremes of humidity.
+func HeatIndex(temp, rh float64) *float64 {
+	t := temp*9/5 + 32 // The regression is in Fahrenheit.
+	if t < 80 {
+		return nil
+	}
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh -
+		0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	c := (hi - 32) * 5 / 9
+	return &c
+}
+
+// Humidex returns the Canadian humidex for an air temperature and dew
+// point.
+func Humidex(temp, dew float64) float64 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dew)))
+	return temp + 0.5555*(e-10)
+}
+
+// ApparentTemperature returns the Australian Bureau of Meteorology's
+// apparent temperature, from Steadman (1994), for an air temperature,
+// relative humidity and wind speed, in the shade.
+func ApparentTemperature(temp, rh, speed float64) float64 {
+	return temp + 0.33*vaporPressure(temp, rh) - 0.70*speed/3.6 - 4.00
+}
+
+// FeelsLike returns how warm or cold the weather feels, and the name of
+// the measure used: wind chill when it is cold and windy, the heat index
+// when it is hot, and otherwise the apparent temperature.  The dew point
+// and wind speed may be nil if they are unknown, in which case the
+// measures needing them are not used, and the air temperature itself may
+// be returned with the name "temperature".
+func FeelsLike(temp float64, dew, speed *float64) (float64, string) {
+	if speed != nil {
+		if chill := WindChill(temp, *speed); chill != nil {
+			return *chill, "wind chill"
+		}
+	}
+	if dew == nil {
+		return temp, "temperature"
+	}
+	rh := RelativeHumidity(temp, *dew)
+	if hi := HeatIndex(temp, rh); hi != nil {
+		return *hi, "heat index"
+	}
+	if speed == nil {
+		return temp, "temperature"
+	}
+	return ApparentTemperature(temp, rh, *speed), "feels like"
+}
@@ -0,0 +1,161 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meteo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRelativeHumidity(t *testing.T) {
+	cases := []struct{ temp, dew, want float64 }{
+		{20, 10, 52.5},
+		{25, 25, 100},
+		{30, 15, 40.3},
+	}
+	for _, tt := range cases {
+		if got := RelativeHumidity(tt.temp, tt.dew); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("RelativeHumidity(%g, %g) = %.1f, want %g", tt.temp, tt.dew, got, tt.want)
+		}
+	}
+}
+
+// Values from the Environment Canada wind chill table.
+func TestWindChill(t *testing.T) {
+	cases := []struct{ temp, speed, want float64 }{
+		{-10, 30, -20},
+		{0, 10, -3},
+		{-20, 50, -35},
+		{5, 20, 1},
+	}
+	for _, tt := range cases {
+		got := WindChill(tt.temp, tt.speed)
+		if got == nil {
+			t.Errorf("WindChill(%g, %g) = nil, want %g", tt.temp, tt.speed, tt.want)
+		} else if math.Abs(*got-tt.want) > 0.5 {
+			t.Errorf("WindChill(%g, %g) = %.1f, want %g", tt.temp, tt.speed, *got, tt.want)
+		}
+	}
+	if got := WindChill(15, 30); got != nil {
+		t.Errorf("WindChill(15, 30) = %g, want nil", *got)
+	}
+	if got := WindChill(0, 3); got != nil {
+		t.Errorf("WindChill(0, 3) = %g, want nil", *got)
+	}
+}
+
+// Values from the NWS heat index table, in Fahrenheit.
+func TestHeatIndex(t *testing.T) {
+	cases := []struct{ temp, rh, want float64 }{
+		{90, 70, 106},
+		{100, 40, 109},
+		{86, 90, 105},
+		{80, 40, 80},
+	}
+	for _, tt := range cases {
+		got := HeatIndex((tt.temp-32)*5/9, tt.rh)
+		if got == nil {
+			t.Errorf("HeatIndex(%g°F, %g) = nil, want %g°F", tt.temp, tt.rh, tt.want)
+			continue
+		}
+		if f := *got*9/5 + 32; math.Abs(f-tt.want) > 1 {
+			t.Errorf("HeatIndex(%g°F, %g) = %.1f°F, want %g°F", tt.temp, tt.rh, f, tt.want)
+		}
+	}
+	if got := HeatIndex(20, 50); got != nil {
+		t.Errorf("HeatIndex(20, 50) = %g, want nil", *got)
+	}
+}
+
+// Values from the Environment Canada humidex table.
+func TestHumidex(t *testing.T) {
+	cases := []struct{ temp, dew, want float64 }{
+		{30, 15, 34},
+		{30, 25, 42},
+		{35, 20, 43},
+	}
+	for _, tt := range cases {
+		if got := Humidex(tt.temp, tt.dew); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("Humidex(%g, %g) = %.1f, want %g", tt.temp, tt.dew, got, tt.want)
+		}
+	}
+}
+
+func TestApparentTemperature(t *testing.T) {
+	// Humid and still feels warmer; dry and windy feels cooler.
+	if got := ApparentTemperature(30, 70, 0); got < 33 || got > 36 {
+		t.Errorf("ApparentTemperature(30, 70, 0) = %.1f, want about 34", got)
+	}
+	if got := ApparentTemperature(20, 30, 36); got > 15 {
+		t.Errorf("ApparentTemperature(20, 30, 36) = %.1f, want below 15", got)
+	}
+}
+
+func TestFeelsLike(t *testing.T) {
+	f := func(x float64) *float64 { return &x }
+	cases := []struct {
+		temp       float64
+		dew, speed *float64
+		want       string
+	}{
+		{0, f(-5), f(20), "wind chill"},
+		{0, nil, f(20), "wind chill"},
+		{32, f(24), f(10), "heat index"},
+		{32, f(24), nil, "heat index"},
+		{18, f(10), f(10), "feels like"},
+		{18, nil, f(10), "temperature"},
+		{18, f(10), nil, "temperature"},
+	}
+	for _, tt := range cases {
+		if _, got := FeelsLike(tt.temp, tt.dew, tt.speed); got != tt.want {
+			t.Errorf("FeelsLike(%g, %v, %v) is %q, want %q", tt.temp, tt.dew, tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestZambretti(t *testing.T) {
+	january := time.Date(2012, 1, 15, 12, 0, 0, 0, time.UTC)
+	july := time.Date(2012, 7, 15, 12, 0, 0, 0, time.UTC)
+	october := time.Date(2012, 10, 15, 12, 0, 0, 0, time.UTC)
+	north, south, west := 0.0, 180.0, 270.0
+	cases := []struct {
+		pressure, tendency float64
+		wind               *float64
+		t                  time.Time
+		lat                float64
+		want               string
+	}{
+		{1030, 2, &north, january, 37.8, "settled fine"},
+		{1000, -2, &south, july, 37.8, "stormy, much rain"},
+		{1015, 0.5, &west, october, 37.8, "fine, possible showers"},
+		{1015, 0, nil, october, 37.8, "fine weather"},
+		// A northerly in the southern hemisphere is like a southerly
+		// in the northern.
+		{1015, 0, &north, january, -33.9, "showery, bright intervals"},
+		{1020, 2, nil, july, 37.8, "settled fine"},
+		{1020, 2, nil, january, 37.8, "fine weather"},
+		// Off the scale.
+		{920, -5, nil, january, 37.8, "stormy, much rain"},
+		{1080, 5, nil, january, 37.8, "settled fine"},
+	}
+	for _, tt := range cases {
+		got := Zambretti(tt.pressure, tt.tendency, tt.wind, tt.t, tt.lat)
+		if got != tt.want {
+			t.Errorf("Zambretti(%g, %g, %v, %v, %g) = %q, want %q",
+				tt.pressure, tt.tendency, tt.wind, tt.t, tt.lat, got, tt.want)
+		}
+	}
+}
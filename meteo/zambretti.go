@@ -0,0 +1,116 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meteo
+
+import (
+	"math"
+	"time"
+)
+
+// The Zambretti forecaster was a slide rule sold by Negretti and Zambra
+// in 1915.  It forecasts the next 12 hours or so from the sea-level
+// pressure, whether it is rising or falling, the wind direction and the
+// season.  This follows the well-known reconstruction by beteljuice.
+
+var zambrettiForecasts = [...]string{
+	"settled fine",
+	"fine weather",
+	"becoming fine",
+	"fine, becoming less settled",
+	"fine, possible showers",
+	"fairly fine, improving",
+	"fairly fine, possible showers early",
+	"fairly fine, showery later",
+	"showery early, improving",
+	"changeable, mending",
+	"fairly fine, showers likely",
+	"rather unsettled, clearing later",
+	"unsettled, probably improving",
+	"showery, bright intervals",
+	"showery, becoming less settled",
+	"changeable, some rain",
+	"unsettled, short fine intervals",
+	"unsettled, rain later",
+	"unsettled, some rain",
+	"mostly very unsettled",
+	"occasional rain, worsening",
+	"rain at times, very unsettled",
+	"rain at frequent intervals",
+	"rain, very unsettled",
+	"stormy, may improve",
+	"stormy, much rain",
+}
+
+// Indexes into zambrettiForecasts, for each of 22 steps of pressure from
+// low to high, when the pressure is falling, steady or rising.
+var (
+	zambrettiFalling = [22]int{25, 25, 25, 25, 25, 25, 25, 25, 23, 23, 21, 20, 17, 14, 7, 3, 1, 1, 1, 0, 0, 0}
+	zambrettiSteady  = [22]int{25, 25, 25, 25, 25, 25, 23, 23, 22, 18, 15, 13, 10, 4, 1, 1, 0, 0, 0, 0, 0, 0}
+	zambrettiRising  = [22]int{25, 25, 25, 24, 24, 19, 16, 12, 11, 9, 8, 6, 5, 2, 1, 1, 0, 0, 0, 0, 0, 0}
+)
+
+// Adjustments to the pressure, as percentages of the scale, for wind from
+// each of the 16 points of the compass clockwise from north, in the
+// northern hemisphere.  Northerlies bring better weather than southerlies.
+var zambrettiWind = [16]float64{
+	6, 5, 5, 2, -0.5, -2, -5, -8.5, -12, -10, -6, -4.5, -3, -0.5, 1.5, 3,
+}
+
+// The scale of the forecaster, in hPa.
+const zambrettiLow, zambrettiHigh = 950, 1050
+
+// SteadyPressure is the 3-hour change in pressure, in hPa, from which it
+// is considered to be rising or falling rather than steady.
+const SteadyPressure = 1
+
+// Zambretti forecasts the weather for a sea-level pressure in hPa, its
+// change over the last three hours, and the direction the wind is from
+// in degrees clockwise from north, or nil if there is none.  The season
+// is taken from t and the hemisphere from lat.
+func Zambretti(pressure, tendency float64, wind *float64, t time.Time, lat float64) string {
+	const scale = zambrettiHigh - zambrettiLow
+	p := pressure
+	if wind != nil {
+		dir := *wind
+		if lat < 0 {
+			dir += 180
+		}
+		p += zambrettiWind[int(math.Mod(dir+11.25, 360)/22.5)%16] / 100 * scale
+	}
+	summer := t.Month() >= time.April && t.Month() <= time.September
+	if lat < 0 {
+		summer = !summer
+	}
+	table := zambrettiSteady
+	switch {
+	case tendency >= SteadyPressure:
+		table = zambrettiRising
+		if summer {
+			p += 7. / 100 * scale
+		}
+	case tendency <= -SteadyPressure:
+		table = zambrettiFalling
+		if summer {
+			p -= 7. / 100 * scale
+		}
+	}
+	step := int(math.Floor((p - zambrettiLow) / (scale / 22.)))
+	if step < 0 {
+		step = 0
+	} else if step > 21 {
+		step = 21
+	}
+	return zambrettiForecasts[table[step]]
+}
@@ -0,0 +1,161 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solar
+
+import (
+	"sort"
+	"time"
+)
+
+// Kind identifies a daily event in the motion of the sun.
+type Kind int
+
+const (
+	Sunrise Kind = iota
+	Sunset
+	Noon
+	CivilDawn
+	CivilDusk
+	NauticalDawn
+	NauticalDusk
+	AstronomicalDawn
+	AstronomicalDusk
+)
+
+var kindNames = []string{
+	"sunrise",
+	"sunset",
+	"solar noon",
+	"civil dawn",
+	"civil dusk",
+	"nautical dawn",
+	"nautical dusk",
+	"astronomical dawn",
+	"astronomical dusk",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "unknown"
+	}
+	return kindNames[k]
+}
+
+// Condition tells whether an event happened, and if not, why not.
+type Condition int
+
+const (
+	// Occurred means the event happened at the given time.
+	Occurred Condition = iota
+	// Above means the sun stayed above the event's elevation for the
+	// whole solar day, as in polar day.
+	Above
+	// Below means the sun stayed below the event's elevation for the
+	// whole solar day, as in polar night.
+	Below
+)
+
+// Event is a sun event, or the lack of one on a solar day.
+type Event struct {
+	Kind Kind
+	// Time is when the event happened.  If it did not happen,
+	// Time is solar noon on the day it was missed.
+	Time      time.Time
+	Condition Condition
+}
+
+func (e Event) String() string {
+	switch {
+	case e.Condition == Occurred:
+		return e.Kind.String()
+	case e.Kind == Sunrise || e.Kind == Sunset:
+		if e.Condition == Above {
+			return "no " + e.Kind.String() + " (polar day)"
+		}
+		return "no " + e.Kind.String() + " (polar night)"
+	case e.Condition == Above:
+		return "no " + e.Kind.String() + " (sun stays above)"
+	}
+	return "no " + e.Kind.String() + " (sun stays below)"
+}
+
+// Each pair of rising and setting events, with the sun's elevation.
+var levels = []struct {
+	rise, set Kind
+	el        float64
+}{
+	{Sunrise, Sunset, horizon},
+	{CivilDawn, CivilDusk, -Civil},
+	{NauticalDawn, NauticalDusk, -Nautical},
+	{AstronomicalDawn, AstronomicalDusk, -Astronomical},
+}
+
+// Events returns every sun event from t1 up to but not including t2, in
+// order.  For each solar day on which an event did not happen, an Event
+// is returned with the reason instead.  Times are in the same location
+// as t1.
+func Events(t1, t2 time.Time, lat, lng float64) []Event {
+	var events []Event
+	add := func(e Event) {
+		if !e.Time.Before(t1) && e.Time.Before(t2) {
+			e.Time = e.Time.In(t1.Location())
+			events = append(events, e)
+		}
+	}
+
+	day := t1.UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	for ; day.Before(t2.Add(24 * time.Hour)); day = day.Add(24 * time.Hour) {
+		noon := transit(day, lng)
+		add(Event{Kind: Noon, Time: noon})
+		for _, l := range levels {
+			rose, set := false, false
+			for _, a := range []time.Time{noon.Add(-12 * time.Hour), noon} {
+				tt, up, ok := crossing(a, a.Add(12*time.Hour), lat, lng, l.el)
+				switch {
+				case !ok:
+				case up:
+					rose = true
+					add(Event{Kind: l.rise, Time: tt})
+				default:
+					set = true
+					add(Event{Kind: l.set, Time: tt})
+				}
+			}
+			c := Above
+			if elevation(noon, lat, lng) < l.el {
+				c = Below
+			}
+			if !rose {
+				add(Event{Kind: l.rise, Time: noon, Condition: c})
+			}
+			if !set {
+				add(Event{Kind: l.set, Time: noon, Condition: c})
+			}
+		}
+	}
+	sort.Sort(byTime(events))
+	return events
+}
+
+type byTime []Event
+
+func (e byTime) Len() int      { return len(e) }
+func (e byTime) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byTime) Less(i, j int) bool {
+	if e[i].Time.Equal(e[j].Time) {
+		return e[i].Kind < e[j].Kind
+	}
+	return e[i].Time.Before(e[j].Time)
+}
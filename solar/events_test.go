@@ -0,0 +1,76 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	pst := time.FixedZone("PST", -8*3600)
+	when := time.Date(2012, 1, 4, 0, 0, 0, 0, pst)
+	events := Events(when, when.Add(24*time.Hour), sfLat, sfLng)
+	want := []Kind{
+		AstronomicalDawn, NauticalDawn, CivilDawn, Sunrise, Noon,
+		Sunset, CivilDusk, NauticalDusk, AstronomicalDusk,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(events), len(want), events)
+	}
+	for i, e := range events {
+		if e.Kind != want[i] || e.Condition != Occurred {
+			t.Errorf("event %d: got %s, want %s", i, e, want[i])
+		}
+		if e.Time.Location() != pst {
+			t.Errorf("event %d in %s, want %s", i, e.Time.Location(), pst)
+		}
+	}
+	if rise := Rise(when, sfLat, sfLng); !events[3].Time.Equal(rise) {
+		t.Errorf("sunrise event at %s, Rise returns %s", events[3].Time, rise)
+	}
+}
+
+func TestEventConditions(t *testing.T) {
+	cases := []struct {
+		when     string
+		lat, lng float64
+		kind     Kind
+		want     string
+	}{
+		{"2012-12-21T00:00:00Z", 69.65, 18.96, Sunrise, "no sunrise (polar night)"},
+		{"2012-12-21T00:00:00Z", 69.65, 18.96, Sunset, "no sunset (polar night)"},
+		{"2012-06-21T00:00:00Z", 69.65, 18.96, Sunset, "no sunset (polar day)"},
+		{"2012-06-21T00:00:00Z", 51.5, 0, AstronomicalDusk, "no astronomical dusk (sun stays above)"},
+		{"2012-12-21T00:00:00Z", 89, 0, CivilDawn, "no civil dawn (sun stays below)"},
+	}
+	for _, tt := range cases {
+		when, _ := time.Parse(time.RFC3339, tt.when)
+		var found bool
+		for _, e := range Events(when, when.Add(24*time.Hour), tt.lat, tt.lng) {
+			if e.Kind != tt.kind {
+				continue
+			}
+			found = true
+			if e.String() != tt.want {
+				t.Errorf("%s at %g, %g: got %q, want %q",
+					tt.when, tt.lat, tt.lng, e, tt.want)
+			}
+		}
+		if !found {
+			t.Errorf("%s at %g, %g: no %s event", tt.when, tt.lat, tt.lng, tt.kind)
+		}
+	}
+}
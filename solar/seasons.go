@@ -0,0 +1,48 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Seasons returns the times of the March equinox, June solstice,
+// September equinox and December solstice in year, in UTC.  They are
+// accurate to within about a quarter of an hour.
+func Seasons(year int) [4]time.Time {
+	var s [4]time.Time
+	for i := range s {
+		// The sun's apparent longitude is a multiple of 90° at
+		// each, within a few days of the 21st.
+		target := float64(i) * math.Pi / 2
+		diff := func(t time.Time) float64 {
+			lambda, _, _ := sun(t)
+			return math.Remainder(lambda-target, 2*math.Pi)
+		}
+		a := time.Date(year, time.Month(3*i+3), 16, 0, 0, 0, 0, time.UTC)
+		b := a.Add(10 * 24 * time.Hour)
+		for b.Sub(a) > time.Second {
+			m := a.Add(b.Sub(a) / 2)
+			if diff(m) < 0 {
+				a = m
+			} else {
+				b = m
+			}
+		}
+		s[i] = b.Truncate(time.Second)
+	}
+	return s
+}
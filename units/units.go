@@ -0,0 +1,160 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+ units has types for physical quantities, and converts them to and from
+ the units they are shown in.
+
+ Each quantity is stored in one unit, and each unit converts values into
+ and out of it:
+
+	s := units.Knots.Of(12)
+	fmt.Println(s.In(units.KilometresPerHour)) // 22.224
+*/
+package units
+
+// Temperature is a temperature in degrees Celsius.
+type Temperature float64
+
+// Speed is a speed in metres per second.
+type Speed float64
+
+// Pressure is a pressure in hectopascals.
+type Pressure float64
+
+// Distance is a distance in metres.
+type Distance float64
+
+// TemperatureUnit is a unit a Temperature can be shown in.
+type TemperatureUnit int
+
+// Temperature units.
+const (
+	Celsius TemperatureUnit = iota
+	Fahrenheit
+)
+
+// Of returns the Temperature that is v in unit u.
+func (u TemperatureUnit) Of(v float64) Temperature {
+	if u == Fahrenheit {
+		return Temperature((v - 32) * 5 / 9)
+	}
+	return Temperature(v)
+}
+
+// In returns the temperature's value in unit u.
+func (t Temperature) In(u TemperatureUnit) float64 {
+	if u == Fahrenheit {
+		return float64(t)*9/5 + 32
+	}
+	return float64(t)
+}
+
+// String returns the unit's symbol, such as "°F".
+func (u TemperatureUnit) String() string {
+	return [...]string{"°C", "°F"}[u]
+}
+
+// SpeedUnit is a unit a Speed can be shown in.
+type SpeedUnit int
+
+// Speed units.
+const (
+	KilometresPerHour SpeedUnit = iota
+	MetresPerSecond
+	MilesPerHour
+	Knots
+)
+
+// Metres per second in each speed unit.
+var speeds = [...]float64{1 / 3.6, 1, 1609.344 / 3600, 1852. / 3600}
+
+// Of returns the Speed that is v in unit u.
+func (u SpeedUnit) Of(v float64) Speed { return Speed(v * speeds[u]) }
+
+// In returns the speed's value in unit u.
+func (s Speed) In(u SpeedUnit) float64 { return float64(s) / speeds[u] }
+
+// String returns the unit's symbol, such as "km/h".
+func (u SpeedUnit) String() string {
+	return [...]string{"km/h", "m/s", "mph", "kn"}[u]
+}
+
+// PressureUnit is a unit a Pressure can be shown in.
+type PressureUnit int
+
+// Pressure units.
+const (
+	Hectopascals PressureUnit = iota
+	InchesOfMercury
+)
+
+// Hectopascals in each pressure unit.
+var pressures = [...]float64{1, 33.8639}
+
+// Of returns the Pressure that is v in unit u.
+func (u PressureUnit) Of(v float64) Pressure { return Pressure(v * pressures[u]) }
+
+// In returns the pressure's value in unit u.
+func (p Pressure) In(u PressureUnit) float64 { return float64(p) / pressures[u] }
+
+// String returns the unit's symbol, such as "hPa".
+func (u PressureUnit) String() string {
+	return [...]string{"hPa", "inHg"}[u]
+}
+
+// DistanceUnit is a unit a Distance can be shown in.
+type DistanceUnit int
+
+// Distance units.
+const (
+	Kilometres DistanceUnit = iota
+	Miles
+	NauticalMiles
+)
+
+// Metres in each distance unit.
+var distances = [...]float64{1000, 1609.344, 1852}
+
+// Of returns the Distance that is v in unit u.
+func (u DistanceUnit) Of(v float64) Distance { return Distance(v * distances[u]) }
+
+// In returns the distance's value in unit u.
+func (d Distance) In(u DistanceUnit) float64 { return float64(d) / distances[u] }
+
+// String returns the unit's symbol, such as "nmi".
+func (u DistanceUnit) String() string {
+	return [...]string{"km", "mi", "nmi"}[u]
+}
+
+// System is the choice of unit for each kind of quantity.  The zero
+// System is Metric.
+type System struct {
+	Temperature TemperatureUnit
+	Speed       SpeedUnit
+	Pressure    PressureUnit
+	Distance    DistanceUnit
+}
+
+// Common systems of units.
+var (
+	// Metric units are SI, but with speeds in km/h and pressures
+	// in hectopascals, as weather is usually given.
+	Metric = System{Celsius, KilometresPerHour, Hectopascals, Kilometres}
+	// Imperial units are those used in the US.
+	Imperial = System{Fahrenheit, MilesPerHour, InchesOfMercury, Miles}
+	// Marine units are metric, but with speeds in knots and
+	// distances in nautical miles.
+	Marine = System{Celsius, Knots, Hectopascals, NauticalMiles}
+)
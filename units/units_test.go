@@ -0,0 +1,55 @@
+// Copyright 2012 Michael Shields
+// 
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// 
+//     http://www.apache.org/licenses/LICENSE-2.0
+// 
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConversions(t *testing.T) {
+	cases := []struct {
+		name      string
+		got, want float64
+	}{
+		{"0°C in °F", Celsius.Of(0).In(Fahrenheit), 32},
+		{"100°C in °F", Celsius.Of(100).In(Fahrenheit), 212},
+		{"-40°F in °C", Fahrenheit.Of(-40).In(Celsius), -40},
+		{"10 m/s in km/h", MetresPerSecond.Of(10).In(KilometresPerHour), 36},
+		{"10 kn in km/h", Knots.Of(10).In(KilometresPerHour), 18.52},
+		{"60 mph in km/h", MilesPerHour.Of(60).In(KilometresPerHour), 96.56064},
+		{"1013.25 hPa in inHg", Hectopascals.Of(1013.25).In(InchesOfMercury), 29.921},
+		{"1 nmi in km", NauticalMiles.Of(1).In(Kilometres), 1.852},
+		{"1 mi in nmi", Miles.Of(1).In(NauticalMiles), 0.868976},
+	}
+	for _, tt := range cases {
+		if math.Abs(tt.got-tt.want) > 0.001 {
+			t.Errorf("%s: got %g, want %g", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestSystems(t *testing.T) {
+	var zero System
+	if zero != Metric {
+		t.Errorf("zero System is %+v, want Metric", zero)
+	}
+	if s := Marine.Speed.String(); s != "kn" {
+		t.Errorf("Marine speed unit is %q, want kn", s)
+	}
+	if s := Imperial.Temperature.String(); s != "°F" {
+		t.Errorf("Imperial temperature unit is %q, want °F", s)
+	}
+}